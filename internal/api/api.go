@@ -1,38 +1,64 @@
 package api
 
 import (
-	"database/sql"
 	"encoding/json"
-
 	"errors"
-	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
 	"github.com/Kovarniykrab/finishGolang/internal/database"
 	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/query"
 	"github.com/Kovarniykrab/finishGolang/internal/util"
 )
 
 type TasksResp struct {
-	Tasks []*domain.Task `json:"tasks"`
+	Tasks []taskItem `json:"tasks"`
+	// NextCursor передаётся в cursor для следующей страницы; на последней странице отсутствует.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total — число задач под фильтрами без учёта страниц, как в X-Total-Count.
+	// Передаётся только при постраничном запросе (limit или cursor): клиенты без
+	// пагинации ожидают в ответе лишь список tasks.
+	Total *int `json:"total,omitempty"`
 }
 
-type DB struct {
-	*sql.DB
+// taskItem — задача в списке вместе с её ETag и, при полнотекстовом поиске,
+// фрагментом текста. Фрагмент не содержит разметки: highlights — интервалы
+// совпадений [начало, конец) в символах snippet.
+type taskItem struct {
+	*domain.Task
+	ETag       string   `json:"etag"`
+	Snippet    string   `json:"snippet,omitempty"`
+	Highlights [][2]int `json:"highlights,omitempty"`
 }
 
-func RegisterHandlers(mux *http.ServeMux, db *sql.DB) {
-	dbs := &DB{db}
-	mux.HandleFunc("/api/nextdate", util.NextDateHandler)
-	mux.HandleFunc("/api/tasks", tasksHandler(db))
-	mux.HandleFunc("/api/task", taskAll(dbs))
-	mux.HandleFunc("/api/task/done", dbs.completedTaskHandler)
-	// http.HandleFunc("/api/signin"
+type DB struct {
+	store database.Store
+	// loc — часовой пояс, в котором определяется «сегодня»
+	loc *time.Location
+}
 
+func RegisterHandlers(mux *http.ServeMux, store database.Store, loc *time.Location) {
+	dbs := &DB{store: store, loc: loc}
+	mux.HandleFunc("/api/nextdate", dbs.nextDateHandler)
+	mux.HandleFunc("/api/nextdates", dbs.nextDatesHandler)
+	mux.HandleFunc("/api/repeat", repeatHandler)
+	mux.HandleFunc("/api/signin", signinHandler)
+	mux.HandleFunc("/api/register", dbs.registerHandler)
+	mux.HandleFunc("/api/login", dbs.loginHandler)
+	mux.HandleFunc("/api/tasks", dbs.requireAuth(dbs.tasksHandler))
+	mux.HandleFunc("/api/task", dbs.requireAuth(taskAll(dbs)))
+	mux.HandleFunc("/api/task/done", dbs.requireAuth(dbs.completedTaskHandler))
+	mux.HandleFunc("/api/task/skip", dbs.requireAuth(dbs.skipTaskHandler))
+	mux.HandleFunc("/api/task/snooze", dbs.requireAuth(dbs.snoozeTaskHandler))
+	mux.HandleFunc("/api/task/exceptions", dbs.requireAuth(dbs.exceptionsHandler))
+	mux.HandleFunc("/api/task/history", dbs.requireAuth(dbs.historyHandler))
+	mux.HandleFunc("/api/task/reopen", dbs.requireAuth(dbs.reopenTaskHandler))
 }
 func taskAll(d *DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -43,6 +69,8 @@ func taskAll(d *DB) http.HandlerFunc {
 			d.getTaskHandler(w, r)
 		case http.MethodPut:
 			d.updateTaskHandler(w, r)
+		case http.MethodPatch:
+			d.patchTaskHandler(w, r)
 		case http.MethodDelete:
 			d.deleteHandler(w, r)
 		default:
@@ -52,7 +80,7 @@ func taskAll(d *DB) http.HandlerFunc {
 }
 
 func (d *DB) getTaskHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println(r.URL)
+	slog.Debug("get task", "url", r.URL.String())
 	idS := r.URL.Query().Get("id")
 	if idS == "" {
 		sendJSONError(w, http.StatusBadRequest, "id is required")
@@ -63,24 +91,14 @@ func (d *DB) getTaskHandler(w http.ResponseWriter, r *http.Request) {
 		sendJSONError(w, http.StatusBadRequest, "id is required")
 		return
 	}
-	task, err := database.GetTaskStory(d.DB, id)
+	task, err := d.store.Get(auth.UserID(r.Context()), id)
 	if err != nil {
-		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		sendStoreError(w, err)
 		return
 	}
-	fmt.Println(task)
+	slog.Debug("task loaded", "task", task)
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{
-		"id":      strconv.FormatInt(task.ID, 10),
-		"date":    task.Date,
-		"title":   task.Title,
-		"comment": task.Comment,
-		"repeat":  task.Repeat,
-	}); err != nil {
-		log.Printf("Failed to encode response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-	}
+	sendTask(w, task)
 }
 
 func (d *DB) deleteHandler(w http.ResponseWriter, r *http.Request) {
@@ -94,8 +112,18 @@ func (d *DB) deleteHandler(w http.ResponseWriter, r *http.Request) {
 		sendJSONError(w, http.StatusBadRequest, "invalid id format")
 		return
 	}
-	if err := database.DeleteTaskStory(d.DB, id); err != nil {
-		sendJSONError(w, http.StatusInternalServerError, err.Error())
+	version, err := d.expectedVersion(r, id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+	if scope := r.URL.Query().Get("scope"); scope != "" && scope != domain.ScopeSeries {
+		err = d.store.DeleteOccurrence(auth.UserID(r.Context()), id, r.URL.Query().Get("occurrence"), scope, time.Now().In(d.loc), version)
+	} else {
+		err = d.store.Delete(auth.UserID(r.Context()), id, version)
+	}
+	if err != nil {
+		sendStoreError(w, err)
 		return
 	}
 
@@ -106,7 +134,12 @@ func (d *DB) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// updateTaskHandler полностью заменяет задачу: непереданные поля очищаются.
 func (d *DB) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if scope := r.URL.Query().Get("scope"); scope != "" && scope != domain.ScopeSeries {
+		sendJSONError(w, http.StatusBadRequest, "scope "+scope+" is supported only by PATCH and DELETE")
+		return
+	}
 
 	var t domain.Task
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
@@ -114,71 +147,202 @@ func (d *DB) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if t.Title == "" {
-		sendJSONError(w, http.StatusBadRequest, "Название не может быть пустым")
-		return
-	}
-
 	// Проверяем, что ID задан
 	if t.ID == 0 {
 		sendJSONError(w, http.StatusBadRequest, "ошибка id is required")
 		return
 	}
 
-	task, err := database.UpdateTaskStory(d.DB, t, t.ID)
+	version, err := d.expectedVersion(r, t.ID)
 	if err != nil {
-		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		sendStoreError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{
+	task, err := d.store.Update(auth.UserID(r.Context()), t, version)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	sendTask(w, task)
+}
+
+// patchTaskHandler применяет JSON Merge Patch: отсутствующие поля не меняются,
+// пустая строка или null очищают поле.
+func (d *DB) patchTaskHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	patch, err := domain.ParseTaskPatch(body)
+	if err != nil {
+		var errs domain.FieldErrors
+		if errors.As(err, &errs) {
+			sendValidationError(w, errs)
+		} else {
+			sendJSONError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	idS := r.URL.Query().Get("id")
+	if idS == "" {
+		var ref struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(body, &ref); err == nil {
+			idS = ref.ID
+		}
+	}
+	id, err := strconv.ParseInt(idS, 10, 64)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	version, err := d.expectedVersion(r, id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	// scope=occurrence|following меняет одно повторение или повторения с него
+	var task domain.Task
+	if scope := r.URL.Query().Get("scope"); scope != "" && scope != domain.ScopeSeries {
+		task, err = d.store.PatchOccurrence(auth.UserID(r.Context()), id, r.URL.Query().Get("occurrence"), scope, patch, time.Now().In(d.loc), version)
+	} else {
+		task, err = d.store.Patch(auth.UserID(r.Context()), id, patch, version)
+	}
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	sendTask(w, task)
+}
+
+func sendTask(w http.ResponseWriter, task domain.Task) {
+	resp := map[string]string{
 		"id":      strconv.FormatInt(task.ID, 10),
 		"date":    task.Date,
 		"title":   task.Title,
 		"comment": task.Comment,
 		"repeat":  task.Repeat,
-	}); err != nil {
+	}
+	if task.Remaining > 0 {
+		resp["remaining"] = strconv.Itoa(task.Remaining)
+	}
+	if task.Anchor != "" {
+		resp["anchor"] = task.Anchor
+	}
+	if task.CatchUp != "" {
+		resp["catch_up"] = task.CatchUp
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(task.Version))
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Printf("Failed to encode response: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
-func tasksHandler(db *sql.DB) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+func (d *DB) tasksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	q := database.TaskQuery{
+		Search:     params.Get("search"),
+		DateFrom:   params.Get("date_from"),
+		DateTo:     params.Get("date_to"),
+		RepeatType: params.Get("repeat"),
+		Sort:       params.Get("sort"),
+		Cursor:     params.Get("cursor"),
+		Now:        time.Now().In(d.loc),
+		Limit:      50,
+	}
+
+	// tz позволяет клиенту из другого часового пояса получить свои «today» и «this week».
+	if tz := params.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			sendJSONError(w, http.StatusBadRequest, "unknown time zone "+tz)
 			return
 		}
+		q.Now = q.Now.In(loc)
+	}
 
-		search := r.URL.Query().Get("search")
-		limitStr := r.URL.Query().Get("limit")
-		limit := 50
-
-		if limitStr != "" {
-			l, err := strconv.Atoi(limitStr)
-			if err != nil || l < 1 {
-				sendJSONError(w, http.StatusBadRequest, "Invalid limit")
-				return
-			}
-			limit = l
+	if limitStr := params.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 {
+			sendJSONError(w, http.StatusBadRequest, "Invalid limit")
+			return
 		}
+		q.Limit = l
+	}
 
-		tasks, err := database.GetTasksStory(db, search, limit)
+	filter, err := query.Parse(params.Get("q"))
+	if err != nil {
+		sendValidationError(w, domain.FieldErrors{{Field: "q", Code: domain.CodeFormat, Message: err.Error()}})
+		return
+	}
+	q.Filter = filter
+
+	switch params.Get("mode") {
+	case "":
+	case "fts":
+		q.FullText = true
+	default:
+		sendJSONError(w, http.StatusBadRequest, "mode must be fts")
+		return
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		sendJSONError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	if s := params.Get("has_repeat"); s != "" {
+		v, err := strconv.ParseBool(s)
 		if err != nil {
-			sendJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Database error: %v", err))
+			sendJSONError(w, http.StatusBadRequest, "has_repeat must be true or false")
 			return
 		}
+		q.HasRepeat = &v
+	}
 
-		if tasks == nil {
-			tasks = make([]*domain.Task, 0)
-		}
+	page, err := d.store.List(auth.UserID(r.Context()), q)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(TasksResp{Tasks: tasks}); err != nil {
-			log.Printf("Failed to encode tasks response: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+	items := make([]taskItem, 0, len(page.Tasks))
+	for _, t := range page.Tasks {
+		snippet := page.Snippets[t.ID]
+		items = append(items, taskItem{Task: t, ETag: etag(t.Version), Snippet: snippet.Text, Highlights: snippet.Highlights})
+	}
+
+	resp := TasksResp{Tasks: items, NextCursor: page.NextCursor}
+	if params.Has("limit") || params.Has("cursor") {
+		resp.Total = &page.Total
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode tasks response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
 
@@ -190,52 +354,34 @@ func (d *DB) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task.Title = strings.TrimSpace(task.Title)
-	if task.Title == "" {
-		sendJSONError(w, http.StatusBadRequest, "Title is required")
-		return
-	}
-	if len(task.Title) > 100 {
-		sendJSONError(w, http.StatusBadRequest, "Title is too long (max 100 characters)")
-		return
-	}
-
-	task.Comment = strings.TrimSpace(task.Comment)
-	if len(task.Comment) > 500 {
-		sendJSONError(w, http.StatusBadRequest, "Comment is too long (max 500 characters)")
-		return
-	}
-
-	Now := time.Now()
+	Now := time.Now().In(d.loc)
 
+	task.Normalize()
 	if task.Date == "" {
 		task.Date = Now.Format(util.DateFormat)
 	}
 
-	dateTime, err := time.Parse(util.DateFormat, task.Date)
-	if err != nil {
-		sendJSONError(w, http.StatusBadRequest, "дата представлена в формате, отличном от 20060102")
-		log.Println(err)
+	if errs := task.Validate(); len(errs) > 0 {
+		sendValidationError(w, errs)
 		return
 	}
 
-	if dateTime.Format(util.DateFormat) < Now.Format(util.DateFormat) {
+	if task.Date < Now.Format(util.DateFormat) {
 		if task.Repeat == "" {
 			task.Date = Now.Format(util.DateFormat)
 		} else {
-			nextDate, err := util.NextDate(Now, task.Date, task.Repeat)
+			nextDate, err := util.NextDateContext(r.Context(), Now, task.Date, task.Repeat)
 			if err != nil {
-				sendJSONError(w, http.StatusInternalServerError, "ошибка работы NextDate")
-				log.Println(err)
+				sendValidationError(w, domain.FieldErrors{{Field: "repeat", Code: domain.CodeInvalidRepeat, Message: err.Error()}})
 				return
 			}
 			task.Date = nextDate
 		}
 	}
 
-	id, err := database.AddTaskStory(d.DB, task)
+	id, err := d.store.Add(auth.UserID(r.Context()), task)
 	if err != nil {
-		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		sendStoreError(w, err)
 		return
 	}
 
@@ -245,63 +391,69 @@ func (d *DB) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 func sendJSONError(w http.ResponseWriter, statusCode int, message string) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
 	if err := json.NewEncoder(w).Encode(map[string]string{"error": message}); err != nil {
 		log.Printf("Failed to send JSON error: %v", err)
 	}
 }
 
-func (d *DB) completedTaskHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+// sendValidationError отправляет 400 с общим сообщением и списком ошибок полей.
+func sendValidationError(w http.ResponseWriter, errs domain.FieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"error":  errs.Error(),
+		"errors": errs,
+	}); err != nil {
+		log.Printf("Failed to send JSON error: %v", err)
+	}
+}
+
+// sendStoreError отображает вид ошибки хранилища в HTTP-статус.
+func sendStoreError(w http.ResponseWriter, err error) {
+	var storeErr *database.Error
+	if errors.As(err, &storeErr) && len(storeErr.Fields) > 0 {
+		sendValidationError(w, storeErr.Fields)
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		sendJSONError(w, http.StatusBadRequest, "ID parameter is required")
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		sendJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, database.ErrValidation):
+		sendJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, database.ErrConflict):
+		sendJSONError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, database.ErrPrecondition):
+		sendJSONError(w, http.StatusPreconditionFailed, err.Error())
+	default:
+		log.Printf("Internal error: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (d *DB) completedTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		sendJSONError(w, http.StatusBadRequest, "Invalid ID format")
+	id, ok := queryID(w, r)
+	if !ok {
 		return
 	}
 
-	task, err := database.GetTaskStory(d.DB, id)
+	version, err := d.expectedVersion(r, id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			sendJSONError(w, http.StatusNotFound, "Task not found")
-		} else {
-			sendJSONError(w, http.StatusInternalServerError, err.Error())
-		}
+		sendStoreError(w, err)
 		return
 	}
 
-	now := time.Now().UTC().Truncate(24 * time.Hour)
-
-	if task.Repeat == "" {
-		if err := database.DeleteTaskStory(d.DB, id); err != nil {
-			sendJSONError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-	} else {
-		nextDate, err := util.NextDate(now, task.Date, task.Repeat)
-		if err != nil {
-			sendJSONError(w, http.StatusBadRequest, "Invalid repeat rule: "+err.Error())
-			return
-		}
-
-		// Создаем объект только с обновленной датой
-		updateData := domain.Task{
-			Date: nextDate,
-		}
+	now := time.Now().In(d.loc)
 
-		// Обновляем только дату
-		if _, err = database.UpdateTaskStory(d.DB, updateData, id); err != nil {
-			sendJSONError(w, http.StatusInternalServerError, "Failed to update task date: "+err.Error())
-			return
-		}
+	if err := d.store.Complete(auth.UserID(r.Context()), id, now, version); err != nil {
+		sendStoreError(w, err)
+		return
 	}
 
 	// Возвращаем пустой JSON {}
@@ -310,3 +462,19 @@ func (d *DB) completedTaskHandler(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Failed to encode response: %v", err)
 	}
 }
+
+// queryID разбирает параметр id; при ошибке отправляет ответ 400.
+func queryID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		sendJSONError(w, http.StatusBadRequest, "ID parameter is required")
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, "Invalid ID format")
+		return 0, false
+	}
+	return id, true
+}
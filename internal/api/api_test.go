@@ -0,0 +1,514 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMux(t *testing.T) *http.ServeMux {
+	mux := http.NewServeMux()
+	RegisterHandlers(mux, database.NewMemory(), time.UTC)
+	return mux
+}
+
+func do(t *testing.T, mux http.Handler, method, target string, body any) (int, map[string]any) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewReader(data)))
+
+	var m map[string]any
+	if rec.Body.Len() > 0 && rec.Header().Get("Content-Type") == "application/json" {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m), rec.Body.String())
+	}
+	return rec.Code, m
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC().Format("20060102")
+
+	code, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{"date": today, "title": "Задача"})
+	require.Equal(t, http.StatusOK, code)
+	id := m["id"].(string)
+
+	for _, tc := range []struct {
+		method, target string
+		body           any
+		want           int
+	}{
+		{http.MethodGet, "/api/task", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/task?id=abc", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/task?id=999", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/task?id=" + id, nil, http.StatusOK},
+		{http.MethodPatch, "/api/tasks", nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/tasks?limit=0", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?sort=owner_id", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?order=up", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?has_repeat=maybe", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?date_from=01.02.2024", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?cursor=broken", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?mode=regex", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?sort=rank", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?q=date:31.02.2025", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?q=repeat:w+-tag:work", nil, http.StatusOK},
+		{http.MethodGet, "/api/tasks?search=next+7+days&tz=Europe/Moscow", nil, http.StatusOK},
+		{http.MethodGet, "/api/tasks?search=today&tz=Mars/Olympus", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?search=15.03.2025-01.03.2025", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task", map[string]any{"title": ""}, http.StatusBadRequest},
+		{http.MethodPost, "/api/task", map[string]any{"date": "20240101", "title": "Задача", "repeat": "ooops"}, http.StatusBadRequest},
+		{http.MethodPut, "/api/task", map[string]any{"id": "999", "date": today, "title": "Задача"}, http.StatusNotFound},
+		{http.MethodPut, "/api/task", map[string]any{"id": id, "title": "Задача", "date": "20240192"}, http.StatusBadRequest},
+		{http.MethodPut, "/api/task", map[string]any{"id": id, "title": "Задача", "repeat": "k 1"}, http.StatusBadRequest},
+		{http.MethodGet, "/api/repeat?repeat=k+1", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task", map[string]any{"title": "Задача", "anchor": "done"}, http.StatusBadRequest},
+		{http.MethodPost, "/api/task", map[string]any{"title": "Задача", "repeat": "d 1", "catch_up": "all"}, http.StatusBadRequest},
+		{http.MethodGet, "/api/repeat", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/repeat?repeat=d+1", nil, http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/task?id=999", nil, http.StatusNotFound},
+		{http.MethodPost, "/api/task/skip?id=" + id, nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task/skip?id=999", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/task/skip?id=" + id, nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/task/snooze?id=" + id, nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task/snooze?id=" + id + "&days=many", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task/snooze?id=" + id + "&until=20000101", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task/snooze?days=1", nil, http.StatusBadRequest},
+		{http.MethodPut, "/api/task?scope=occurrence", map[string]any{"id": id, "date": today, "title": "Задача"}, http.StatusBadRequest},
+		{http.MethodPatch, "/api/task?id=" + id + "&scope=occurrence", map[string]any{"title": "Задача"}, http.StatusBadRequest},
+		{http.MethodDelete, "/api/task?id=999&scope=following", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/task/exceptions?id=999", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/task/history?date_from=2024", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/task/history?id=abc", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/task/history?limit=0", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/task/history", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/task/reopen?id=" + id, nil, http.StatusConflict},
+		{http.MethodPost, "/api/task/reopen?id=999", nil, http.StatusNotFound},
+		{http.MethodPost, "/api/task/done?id=999", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/task/done?id=" + id, nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/register", map[string]any{"login": "alice", "password": "password"}, http.StatusOK},
+		{http.MethodPost, "/api/register", map[string]any{"login": "alice", "password": "password"}, http.StatusConflict},
+		{http.MethodPost, "/api/login", map[string]any{"login": "alice", "password": "wrong"}, http.StatusUnauthorized},
+	} {
+		code, m := do(t, mux, tc.method, tc.target, tc.body)
+		assert.Equal(t, tc.want, code, "%s %s", tc.method, tc.target)
+		if tc.want >= http.StatusBadRequest {
+			assert.NotEmpty(t, m["error"], "%s %s", tc.method, tc.target)
+		}
+	}
+}
+
+func TestRegisterWithPassword(t *testing.T) {
+	auth.SetPassword("secret")
+	t.Cleanup(func() { auth.SetPassword("") })
+	mux := newTestMux(t)
+
+	for _, body := range []map[string]any{
+		{"login": "mallory", "password": "password"},
+		{"login": "mallory", "password": "password", "shared_password": "guess"},
+	} {
+		code, m := do(t, mux, http.MethodPost, "/api/register", body)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.NotEmpty(t, m["error"])
+	}
+	code, _ := do(t, mux, http.MethodPost, "/api/login", map[string]any{"login": "mallory", "password": "password"})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, m := do(t, mux, http.MethodPost, "/api/register", map[string]any{"login": "alice", "password": "password", "shared_password": "secret"})
+	require.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, m["token"])
+}
+
+func TestPutAndPatch(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC().Format("20060102")
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{
+		"date": today, "title": "Задача", "comment": "Комментарий", "repeat": "d 7",
+	})
+	id := m["id"].(string)
+
+	// PATCH меняет только переданные поля
+	code, m := do(t, mux, http.MethodPatch, "/api/task?id="+id, map[string]any{"title": "Новая"})
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, "Новая", m["title"])
+	assert.Equal(t, "Комментарий", m["comment"])
+	assert.Equal(t, "d 7", m["repeat"])
+
+	// null и пустая строка очищают поле
+	code, m = do(t, mux, http.MethodPatch, "/api/task", map[string]any{"id": id, "comment": nil, "repeat": ""})
+	require.Equal(t, http.StatusOK, code, m)
+	assert.Equal(t, "", m["comment"])
+	assert.Equal(t, "", m["repeat"])
+
+	for _, tc := range []struct {
+		body any
+		want int
+	}{
+		{map[string]any{"title": ""}, http.StatusBadRequest},
+		{map[string]any{"repeat": "k 1"}, http.StatusBadRequest},
+		{map[string]any{"colour": "red"}, http.StatusBadRequest},
+		{[]string{"title"}, http.StatusBadRequest},
+		{map[string]any{}, http.StatusBadRequest},
+	} {
+		code, m := do(t, mux, http.MethodPatch, "/api/task?id="+id, tc.body)
+		assert.Equal(t, tc.want, code, "%v", tc.body)
+		assert.NotEmpty(t, m["error"], "%v", tc.body)
+	}
+	code, _ = do(t, mux, http.MethodPatch, "/api/task?id=999", map[string]any{"title": "Нет"})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// PUT заменяет задачу целиком: непереданный комментарий очищается
+	do(t, mux, http.MethodPatch, "/api/task?id="+id, map[string]any{"comment": "Снова"})
+	code, m = do(t, mux, http.MethodPut, "/api/task", map[string]any{"id": id, "date": today, "title": "Полностью"})
+	require.Equal(t, http.StatusOK, code, m)
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	assert.Equal(t, "Полностью", m["title"])
+	assert.Equal(t, "", m["comment"])
+	assert.Equal(t, "", m["repeat"])
+}
+
+func TestETags(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC().Format("20060102")
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{"date": today, "title": "Задача", "repeat": "d 1"})
+	id := m["id"].(string)
+
+	send := func(method, target, ifMatch string, body any) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, target, bytes.NewReader(data))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodGet, "/api/task?id="+id, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	tag := rec.Header().Get("ETag")
+	assert.Equal(t, `"1"`, tag)
+
+	var list struct {
+		Tasks []map[string]string `json:"tasks"`
+	}
+	rec = send(http.MethodGet, "/api/tasks", "", nil)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Tasks, 1)
+	assert.Equal(t, tag, list.Tasks[0]["etag"])
+
+	// первая вкладка сохраняет изменения, вторая со старым ETag получает 412
+	rec = send(http.MethodPut, "/api/task", tag, map[string]any{"id": id, "date": today, "title": "Первая", "repeat": "d 1"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	newTag := rec.Header().Get("ETag")
+	assert.Equal(t, `"2"`, newTag)
+
+	for _, tc := range []struct {
+		method, target string
+		body           any
+	}{
+		{http.MethodPut, "/api/task", map[string]any{"id": id, "date": today, "title": "Вторая"}},
+		{http.MethodPatch, "/api/task?id=" + id, map[string]any{"title": "Вторая"}},
+		{http.MethodPost, "/api/task/done?id=" + id, nil},
+		{http.MethodDelete, "/api/task?id=" + id, nil},
+	} {
+		rec := send(tc.method, tc.target, tag, tc.body)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code, "%s %s", tc.method, tc.target)
+		assert.Contains(t, rec.Body.String(), `"error"`)
+	}
+	rec = send(http.MethodPatch, "/api/task?id="+id, `W/"2"`, map[string]any{"title": "Слабый"})
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = send(http.MethodPatch, "/api/task?id="+id, `"1", `+newTag, map[string]any{"title": "Вторая"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = send(http.MethodPost, "/api/task/done?id="+id, `"3"`, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = send(http.MethodDelete, "/api/task?id="+id, "*", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = send(http.MethodDelete, "/api/task?id="+id, `"4"`, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTasksPagination(t *testing.T) {
+	mux := newTestMux(t)
+	for _, title := range []string{"Первая", "Вторая", "Третья"} {
+		code, _ := do(t, mux, http.MethodPost, "/api/task", map[string]any{"date": "20991231", "title": title})
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	var titles []string
+	target := "/api/tasks?limit=2&sort=id&order=desc"
+	for target != "" {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "3", rec.Header().Get("X-Total-Count"))
+
+		var resp struct {
+			Tasks      []map[string]string `json:"tasks"`
+			NextCursor string              `json:"next_cursor"`
+			Total      int                 `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 3, resp.Total)
+		for _, task := range resp.Tasks {
+			titles = append(titles, task["title"])
+		}
+		target = ""
+		if resp.NextCursor != "" {
+			target = "/api/tasks?limit=2&sort=id&order=desc&cursor=" + resp.NextCursor
+		}
+	}
+	assert.Equal(t, []string{"Третья", "Вторая", "Первая"}, titles)
+
+	code, m := do(t, mux, http.MethodGet, "/api/tasks?limit=2&search="+url.QueryEscape("Втор"), nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), m["total"])
+	code, m = do(t, mux, http.MethodGet, "/api/tasks?limit=10&search=nothing", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(0), m["total"])
+	// без limit и cursor ответ остаётся прежним: только tasks
+	code, m = do(t, mux, http.MethodGet, "/api/tasks", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, m, "total")
+}
+
+func TestRepeatConvert(t *testing.T) {
+	mux := newTestMux(t)
+
+	code, m := do(t, mux, http.MethodGet, "/api/repeat?repeat=mw+2:2+count:5", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"rrule": "FREQ=MONTHLY;BYDAY=2TU;COUNT=5", "compact": "mw 2:2 count:5"}, m)
+
+	code, m = do(t, mux, http.MethodGet, "/api/repeat?repeat="+url.QueryEscape("FREQ=MONTHLY;INTERVAL=2"), nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"rrule": "FREQ=MONTHLY;INTERVAL=2"}, m)
+}
+
+func TestNextDates(t *testing.T) {
+	mux := newTestMux(t)
+	get := func(target string) (int, NextDatesResp) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var resp NextDatesResp
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := get("/api/nextdates?now=20240126&date=20240101&repeat=" + url.QueryEscape("m -1,15 2,8") + "&n=3")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, NextDatesResp{Dates: []string{"20240215", "20240229", "20240815"}}, resp)
+
+	code, resp = get("/api/nextdates?date=20240101&repeat=d+7&from=20240201&to=20240229")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, NextDatesResp{Dates: []string{"20240205", "20240212", "20240219", "20240226"}}, resp)
+
+	code, resp = get("/api/nextdates?date=20240101&repeat=d+7&from=20240201&to=20240229&n=2")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, NextDatesResp{Dates: []string{"20240205", "20240212"}, Truncated: true}, resp)
+
+	code, resp = get("/api/nextdates?date=20240101&repeat=d+7+count:7&from=20240201&to=20240229&n=2")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, NextDatesResp{Dates: []string{"20240205", "20240212"}}, resp)
+
+	code, resp = get("/api/nextdates?date=20240101&repeat=d+7&from=20240301&to=20240302")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, NextDatesResp{Dates: []string{}}, resp)
+
+	for _, target := range []string{
+		"/api/nextdates?date=20240101",
+		"/api/nextdates?date=01.01.2024&repeat=d+1",
+		"/api/nextdates?date=20240101&repeat=k+1",
+		"/api/nextdates?date=20240101&repeat=d+1&n=0",
+		"/api/nextdates?date=20240101&repeat=d+1&n=100000",
+		"/api/nextdates?date=20240101&repeat=d+1&from=20240201",
+		"/api/nextdates?date=20240101&repeat=d+1&from=20240301&to=20240201",
+	} {
+		code, _ := get(target)
+		assert.Equal(t, http.StatusBadRequest, code, target)
+	}
+}
+
+func TestNextDateLocation(t *testing.T) {
+	// поясы отстоят на 26 часов, поэтому «сегодня» в них всегда разное
+	for _, loc := range []*time.Location{time.FixedZone("UTC+14", 14*3600), time.FixedZone("UTC-12", -12*3600)} {
+		mux := http.NewServeMux()
+		RegisterHandlers(mux, database.NewMemory(), loc)
+		tomorrow := time.Now().In(loc).AddDate(0, 0, 1).Format("20060102")
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nextdate?date=20240101&repeat=d+1", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, tomorrow, rec.Body.String(), loc.String())
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nextdates?date=20240101&repeat=d+1&n=1", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp NextDatesResp
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, []string{tomorrow}, resp.Dates, loc.String())
+	}
+}
+
+func TestCompletionAnchor(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC()
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{
+		"date": today.AddDate(0, 0, -3).Format("20060102"), "title": "Полить цветы", "repeat": "d 5", "anchor": "completion",
+	})
+	id := m["id"].(string)
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	assert.Equal(t, "completion", m["anchor"])
+
+	code, _ := do(t, mux, http.MethodPost, "/api/task/done?id="+id, nil)
+	require.Equal(t, http.StatusOK, code)
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	assert.Equal(t, today.AddDate(0, 0, 5).Format("20060102"), m["date"])
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nextdate?now=20240126&repeat=d+5&anchor=completion", nil))
+	assert.Equal(t, "20240131", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/nextdate?date=20240101&repeat=d+1&anchor=done", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestCatchUp(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC()
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{"title": "Отчёт", "repeat": "d 1", "catch_up": "spawn"})
+	id := m["id"].(string)
+	// ежедневная задача просрочена на два дня: вчера и сегодня — пропущенные повторения
+	_, m = do(t, mux, http.MethodPatch, "/api/task?id="+id, map[string]any{"date": today.AddDate(0, 0, -2).Format("20060102")})
+	assert.Equal(t, "spawn", m["catch_up"])
+
+	code, _ := do(t, mux, http.MethodPost, "/api/task/done?id="+id, nil)
+	require.Equal(t, http.StatusOK, code)
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	assert.Equal(t, today.AddDate(0, 0, 1).Format("20060102"), m["date"])
+	_, m = do(t, mux, http.MethodGet, "/api/tasks", nil)
+	assert.Len(t, m["tasks"], 3)
+
+	code, m = do(t, mux, http.MethodPatch, "/api/task?id="+id, map[string]any{"catch_up": "one"})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "one", m["catch_up"])
+	_, m = do(t, mux, http.MethodPatch, "/api/task?id="+id, map[string]any{"catch_up": nil})
+	assert.NotContains(t, m, "catch_up")
+}
+
+func TestSkipSnooze(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC()
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{"title": "Планёрка", "repeat": "d 7"})
+	id := m["id"].(string)
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	date := m["date"].(string)
+
+	code, m := do(t, mux, http.MethodPost, "/api/task/skip?id="+id, nil)
+	require.Equal(t, http.StatusOK, code)
+	start, _ := time.Parse("20060102", date)
+	assert.Equal(t, start.AddDate(0, 0, 7).Format("20060102"), m["date"])
+
+	code, m = do(t, mux, http.MethodPost, "/api/task/snooze?id="+id+"&days=2", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, start.AddDate(0, 0, 9).Format("20060102"), m["date"])
+
+	until := today.AddDate(0, 1, 0).Format("20060102")
+	code, m = do(t, mux, http.MethodPost, "/api/task/snooze?id="+id+"&until="+until, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, until, m["date"])
+}
+
+func TestOccurrenceScopes(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC()
+	day := func(n int) string { return today.AddDate(0, 0, n).Format("20060102") }
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{"date": day(0), "title": "Планёрка", "repeat": "d 7"})
+	id := m["id"].(string)
+
+	code, m := do(t, mux, http.MethodPatch, "/api/task?id="+id+"&scope=occurrence&occurrence="+day(7), map[string]any{"title": "Планёрка онлайн"})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, day(7), m["date"])
+	assert.Equal(t, "", m["repeat"])
+	override := m["id"].(string)
+
+	code, m = do(t, mux, http.MethodGet, "/api/task/exceptions?id="+id, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{map[string]any{"task_id": id, "date": day(7), "override_id": override}}, m["exceptions"])
+
+	// отмена текущего повторения переносит серию, пропуская заменённое
+	code, _ = do(t, mux, http.MethodDelete, "/api/task?id="+id+"&scope=occurrence", nil)
+	require.Equal(t, http.StatusOK, code)
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	assert.Equal(t, day(14), m["date"])
+
+	code, m = do(t, mux, http.MethodPatch, "/api/task?id="+id+"&scope=following&occurrence="+day(28), map[string]any{"comment": "новый формат"})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, day(28), m["date"])
+	assert.Equal(t, "d 7", m["repeat"])
+	_, m = do(t, mux, http.MethodGet, "/api/task?id="+id, nil)
+	assert.Equal(t, "d 7 until:"+day(27), m["repeat"])
+}
+
+func TestHistory(t *testing.T) {
+	mux := newTestMux(t)
+	today := time.Now().UTC().Format("20060102")
+
+	_, m := do(t, mux, http.MethodPost, "/api/task", map[string]any{"date": today, "title": "Позвонить"})
+	once := m["id"].(string)
+	_, m = do(t, mux, http.MethodPost, "/api/task", map[string]any{"date": today, "title": "Отчёт", "repeat": "d 7"})
+	weekly := m["id"].(string)
+
+	for _, id := range []string{once, weekly} {
+		code, _ := do(t, mux, http.MethodPost, "/api/task/done?id="+id, nil)
+		require.Equal(t, http.StatusOK, code)
+	}
+	code, _ := do(t, mux, http.MethodPost, "/api/task/skip?id="+weekly, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	code, m = do(t, mux, http.MethodGet, "/api/task/history?date_from="+today, nil)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, m["completions"], 2)
+	assert.NotContains(t, m, "events")
+
+	_, m = do(t, mux, http.MethodGet, "/api/task/history?id="+weekly, nil)
+	completions := m["completions"].([]any)
+	require.Len(t, completions, 1)
+	assert.Equal(t, "Отчёт", completions[0].(map[string]any)["title"])
+	assert.Equal(t, today, completions[0].(map[string]any)["date"])
+	require.Len(t, m["events"], 1)
+	assert.Equal(t, "skip", m["events"].([]any)[0].(map[string]any)["action"])
+
+	code, m = do(t, mux, http.MethodPost, "/api/task/reopen?id="+once, nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, once, m["id"])
+	assert.Equal(t, today, m["date"])
+	code, _ = do(t, mux, http.MethodGet, "/api/task?id="+once, nil)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = do(t, mux, http.MethodPost, "/api/task/reopen?id="+weekly, nil)
+	assert.Equal(t, http.StatusConflict, code)
+}
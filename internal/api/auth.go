@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
+)
+
+type signinReq struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	// SharedPassword — общий пароль TODO_PASSWORD, без него регистрация закрыта.
+	SharedPassword string `json:"shared_password"`
+}
+
+func signinHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !auth.Enabled() {
+		sendJSONError(w, http.StatusBadRequest, "Аутентификация не настроена")
+		return
+	}
+
+	var req signinReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	token, err := auth.Signin(req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrWrongPassword) {
+			sendJSONError(w, http.StatusUnauthorized, "Неверный пароль")
+		} else {
+			sendJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	sendToken(w, token)
+}
+
+func (d *DB) registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req signinReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	// при заданном TODO_PASSWORD регистрироваться могут только знающие его
+	if auth.Enabled() && !auth.CheckShared(req.SharedPassword) {
+		sendJSONError(w, http.StatusForbidden, "Registration requires the shared password")
+		return
+	}
+
+	req.Login = strings.TrimSpace(req.Login)
+	if n := utf8.RuneCountInString(req.Login); n < 3 || n > 64 {
+		sendJSONError(w, http.StatusBadRequest, "Login must be 3-64 characters")
+		return
+	}
+	if utf8.RuneCountInString(req.Password) < 6 {
+		sendJSONError(w, http.StatusBadRequest, "Password is too short (min 6 characters)")
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := d.store.CreateUser(req.Login, hash)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	token, err := auth.NewToken(id, hash, time.Now())
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendToken(w, token)
+}
+
+func (d *DB) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req signinReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, http.StatusBadRequest, "Invalid JSON data")
+		return
+	}
+
+	user, err := d.store.UserByLogin(strings.TrimSpace(req.Login))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err != nil || !auth.CheckPassword(user.PasswordHash, req.Password) {
+		sendJSONError(w, http.StatusUnauthorized, "Неверный логин или пароль")
+		return
+	}
+
+	token, err := auth.NewToken(user.ID, user.PasswordHash, time.Now())
+	if err != nil {
+		sendJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendToken(w, token)
+}
+
+func sendToken(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(auth.TokenTTL.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"token": token}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// requireAuth определяет владельца запроса по cookie token. Без cookie
+// запрос попадает в общее пространство задач, если не задан TODO_PASSWORD.
+func (d *DB) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(auth.CookieName)
+		if err != nil || cookie.Value == "" {
+			if auth.Enabled() {
+				sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		claims, err := auth.Parse(cookie.Value)
+		if err != nil {
+			sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		if claims.UserID == 0 {
+			if !auth.Enabled() || !claims.Matches(auth.Password()) {
+				sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		user, err := d.store.UserByID(claims.UserID)
+		if err != nil {
+			if !errors.Is(err, database.ErrNotFound) {
+				log.Printf("Failed to load user %d: %v", claims.UserID, err)
+			}
+			sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !claims.Matches(user.PasswordHash) {
+			sendJSONError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+
+		next(w, r.WithContext(auth.WithUserID(r.Context(), user.ID)))
+	}
+}
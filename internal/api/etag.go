@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
+)
+
+// etag формирует сильный ETag задачи по её версии.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseETag возвращает версию из сильного ETag; слабые теги при If-Match не совпадают.
+func parseETag(tag string) (int64, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || v < 1 {
+		return 0, false
+	}
+	return v, true
+}
+
+// expectedVersion разбирает If-Match и возвращает версию, которую должна иметь
+// задача id; 0 — заголовка нет или он равен *.
+func (d *DB) expectedVersion(r *http.Request, id int64) (int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return 0, nil
+		}
+		if v, ok := parseETag(tag); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, database.ErrVersionMismatch
+	case 1:
+		return versions[0], nil
+	}
+
+	// Несколько тегов: подходит любой, поэтому сверяем с текущей версией.
+	task, err := d.store.Get(auth.UserID(r.Context()), id)
+	if err != nil {
+		return 0, err
+	}
+	if !slices.Contains(versions, task.Version) {
+		return 0, database.ErrVersionMismatch
+	}
+	return task.Version, nil
+}
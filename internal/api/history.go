@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+)
+
+// HistoryResp — ответ /api/task/history. Events — пропуски и переносы,
+// только для истории одной задачи.
+type HistoryResp struct {
+	Completions []domain.Completion `json:"completions"`
+	Events      []domain.TaskEvent  `json:"events,omitempty"`
+}
+
+// historyHandler возвращает выполнения задачи id или, без id, всех задач
+// пользователя, последние первыми.
+func (d *DB) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	q := database.CompletionQuery{
+		DateFrom: params.Get("date_from"),
+		DateTo:   params.Get("date_to"),
+	}
+	if params.Has("id") {
+		id, ok := queryID(w, r)
+		if !ok {
+			return
+		}
+		q.TaskID = id
+	}
+	if limitStr := params.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 {
+			sendJSONError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		q.Limit = l
+	}
+
+	userID := auth.UserID(r.Context())
+	completions, err := d.store.Completions(userID, q)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+	resp := HistoryResp{Completions: completions}
+	if q.TaskID != 0 {
+		if resp.Events, err = d.store.History(userID, q.TaskID); err != nil {
+			sendStoreError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// reopenTaskHandler возвращает выполненную разовую задачу в список.
+func (d *DB) reopenTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, ok := queryID(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := d.store.Reopen(auth.UserID(r.Context()), id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	sendTask(w, task)
+}
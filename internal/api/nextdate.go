@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// ruleTimeout ограничивает время вычисления дат по правилу в одном запросе.
+const ruleTimeout = 2 * time.Second
+
+// nextDateHandler отвечает простым текстом: фронтенд показывает ответ как есть.
+// С anchor=completion дата считается от now — так, как если бы задачу
+// выполнили сегодня; date тогда можно не передавать. Без now «сегодня»
+// определяется в часовом поясе d.loc.
+func (d *DB) nextDateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	nowStr := r.URL.Query().Get("now")
+	date := r.URL.Query().Get("date")
+	repeat := r.URL.Query().Get("repeat")
+
+	var now time.Time
+	if nowStr == "" {
+		now = time.Now().In(d.loc)
+	} else {
+		if fe := domain.ValidateDate("now", nowStr); fe != nil {
+			http.Error(w, fe.Message, http.StatusBadRequest)
+			return
+		}
+		now, _ = time.Parse(util.DateFormat, nowStr)
+	}
+
+	switch r.URL.Query().Get("anchor") {
+	case "", domain.AnchorSchedule:
+	case domain.AnchorCompletion:
+		date = now.Format(util.DateFormat)
+	default:
+		http.Error(w, "anchor must be schedule or completion", http.StatusBadRequest)
+		return
+	}
+
+	if fe := domain.ValidateDate("date", date); fe != nil {
+		http.Error(w, fe.Message, http.StatusBadRequest)
+		return
+	}
+	if repeat == "" {
+		http.Error(w, "repeat is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), ruleTimeout)
+	defer cancel()
+	nextDate, err := util.NextDateContext(ctx, now, date, repeat)
+	if err != nil && ctx.Err() != nil {
+		http.Error(w, "repeat rule evaluation timed out", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, util.ErrSeriesEnded) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid repeat rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(nextDate))
+}
+
+// NextDatesResp — ответ /api/nextdates. Truncated — в окне from..to есть
+// ещё даты сверх лимита.
+type NextDatesResp struct {
+	Dates     []string `json:"dates"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// nextDatesHandler возвращает несколько следующих дат правила: n дат позже now
+// или все даты в окне from..to, но не больше util.MaxOccurrences.
+func (d *DB) nextDatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	var errs domain.FieldErrors
+	for _, name := range []string{"date", "now", "from", "to"} {
+		if params.Get(name) == "" && name != "date" {
+			continue
+		}
+		if fe := domain.ValidateDate(name, params.Get(name)); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if params.Get("repeat") == "" {
+		errs = append(errs, domain.FieldError{Field: "repeat", Code: domain.CodeRequired, Message: "repeat is required"})
+	}
+	limit := 10
+	if s := params.Get("n"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > util.MaxOccurrences {
+			errs = append(errs, domain.FieldError{Field: "n", Code: domain.CodeFormat,
+				Message: fmt.Sprintf("n must be between 1 and %d", util.MaxOccurrences)})
+		}
+		limit = n
+	}
+	if len(errs) > 0 {
+		sendValidationError(w, errs)
+		return
+	}
+
+	now := time.Now().In(d.loc)
+	if s := params.Get("now"); s != "" {
+		now, _ = time.Parse(util.DateFormat, s)
+	}
+
+	// окно: даты с from по to включительно, from по умолчанию — день после now
+	from, to := params.Get("from"), params.Get("to")
+	if from != "" || to != "" {
+		if to == "" {
+			sendValidationError(w, domain.FieldErrors{{Field: "to", Code: domain.CodeRequired, Message: "to is required with from"}})
+			return
+		}
+		if from != "" {
+			if from > to {
+				sendValidationError(w, domain.FieldErrors{{Field: "from", Code: domain.CodeInvalidDate, Message: "from is after to"}})
+				return
+			}
+			start, _ := time.Parse(util.DateFormat, from)
+			now = start.AddDate(0, 0, -1)
+		}
+		if params.Get("n") == "" {
+			limit = util.MaxOccurrences
+		}
+	}
+
+	date, repeat := params.Get("date"), params.Get("repeat")
+	ctx, cancel := context.WithTimeout(r.Context(), ruleTimeout)
+	defer cancel()
+	dates, err := util.Occurrences(ctx, now, date, repeat, limit, to)
+	if err != nil && ctx.Err() != nil {
+		sendJSONError(w, http.StatusServiceUnavailable, "repeat rule evaluation timed out")
+		return
+	}
+	if err != nil {
+		sendValidationError(w, domain.FieldErrors{{Field: "repeat", Code: domain.CodeInvalidRepeat, Message: "invalid repeat rule: " + err.Error()}})
+		return
+	}
+
+	resp := NextDatesResp{Dates: dates}
+	if dates == nil {
+		resp.Dates = []string{}
+	}
+	if rule, _ := util.ParseRule(repeat); to != "" && len(dates) == limit && (rule.Limits.Count == 0 || rule.Limits.Count > limit) {
+		last, _ := time.Parse(util.DateFormat, dates[len(dates)-1])
+		if more, err := util.Occurrences(ctx, last, date, repeat, 1, to); err == nil && len(more) > 0 {
+			resp.Truncated = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
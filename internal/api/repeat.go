@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// repeatHandler переводит правило повторения между кратким синтаксисом и RRULE.
+// compact отсутствует в ответе, если правило нельзя записать кратко.
+func repeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	repeat := r.URL.Query().Get("repeat")
+	if repeat == "" {
+		sendJSONError(w, http.StatusBadRequest, "repeat is required")
+		return
+	}
+
+	rrule, err := util.ToRRule(repeat)
+	if err != nil {
+		sendJSONError(w, http.StatusBadRequest, "invalid repeat rule: "+err.Error())
+		return
+	}
+	resp := map[string]string{"rrule": rrule}
+	compact, err := util.FromRRule(repeat)
+	switch {
+	case err == nil:
+		resp["compact"] = compact
+	case !errors.Is(err, util.ErrNotCompact):
+		sendJSONError(w, http.StatusBadRequest, "invalid repeat rule: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+)
+
+// skipTaskHandler переносит повторяющуюся задачу на следующее повторение,
+// не отмечая её выполненной.
+func (d *DB) skipTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, ok := queryID(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := d.expectedVersion(r, id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	task, err := d.store.Skip(auth.UserID(r.Context()), id, time.Now().In(d.loc), version)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	sendTask(w, task)
+}
+
+// snoozeTaskHandler откладывает задачу до даты until или на days дней.
+func (d *DB) snoozeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, ok := queryID(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	snooze := database.Snooze{Until: q.Get("until")}
+	if days := q.Get("days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			sendJSONError(w, http.StatusBadRequest, "Invalid days format")
+			return
+		}
+		snooze.Days = n
+	}
+	if snooze.Until == "" && q.Get("days") == "" {
+		sendJSONError(w, http.StatusBadRequest, "until or days parameter is required")
+		return
+	}
+
+	version, err := d.expectedVersion(r, id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	task, err := d.store.Snooze(auth.UserID(r.Context()), id, snooze, time.Now().In(d.loc), version)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	sendTask(w, task)
+}
+
+// ExceptionsResp — ответ /api/task/exceptions.
+type ExceptionsResp struct {
+	Exceptions []domain.TaskException `json:"exceptions"`
+}
+
+// exceptionsHandler возвращает отменённые и заменённые повторения задачи.
+func (d *DB) exceptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, ok := queryID(w, r)
+	if !ok {
+		return
+	}
+
+	exceptions, err := d.store.Exceptions(auth.UserID(r.Context()), id)
+	if err != nil {
+		sendStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ExceptionsResp{Exceptions: exceptions}); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	CookieName = "token"
+	TokenTTL   = 8 * time.Hour
+)
+
+var (
+	ErrWrongPassword = errors.New("неверный пароль")
+	ErrInvalidToken  = errors.New("недействительный токен")
+)
+
+// password и secret задаются при старте; secret хранится в БД.
+var (
+	password string
+	secret   []byte
+)
+
+type Claims struct {
+	UserID int64  `json:"uid,omitempty"`
+	Hash   string `json:"hash"`
+	jwt.RegisteredClaims
+}
+
+type ctxKey struct{}
+
+func SetSecret(s []byte) {
+	secret = s
+}
+
+func SetPassword(p string) {
+	password = p
+}
+
+// Password возвращает общий пароль; пустая строка — аутентификация выключена.
+func Password() string {
+	return password
+}
+
+func Enabled() bool {
+	return Password() != ""
+}
+
+func hash(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+func signingKey() []byte {
+	h := sha256.New()
+	h.Write(secret)
+	h.Write([]byte("todo-jwt:" + Password()))
+	return h.Sum(nil)
+}
+
+// CheckShared сверяет attempt с общим паролем за постоянное время.
+func CheckShared(attempt string) bool {
+	return subtle.ConstantTimeCompare([]byte(attempt), []byte(Password())) == 1
+}
+
+// Signin проверяет общий пароль и выдаёт подписанный токен.
+func Signin(attempt string) (string, error) {
+	if !CheckShared(attempt) {
+		return "", ErrWrongPassword
+	}
+	return NewToken(0, Password(), time.Now())
+}
+
+// NewToken выдаёт токен пользователя userID (0 — общий пароль).
+// В токен встраивается хеш credential, поэтому его смена отзывает токен.
+func NewToken(userID int64, credential string, now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID: userID,
+		Hash:   hash(credential),
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenTTL)),
+		},
+	})
+	signed, err := token.SignedString(signingKey())
+	if err != nil {
+		return "", fmt.Errorf("не удалось подписать токен: %w", err)
+	}
+	return signed, nil
+}
+
+// Parse проверяет подпись и срок действия токена.
+func Parse(signed string) (Claims, error) {
+	var c Claims
+	_, err := jwt.ParseWithClaims(signed, &c, func(*jwt.Token) (any, error) {
+		return signingKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return c, nil
+}
+
+// Matches сообщает, выдан ли токен для текущего значения credential.
+func (c Claims) Matches(credential string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.Hash), []byte(hash(credential))) == 1
+}
+
+func HashPassword(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("не удалось захешировать пароль: %w", err)
+	}
+	return string(h), nil
+}
+
+func CheckPassword(passwordHash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+}
+
+func WithUserID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// UserID возвращает владельца запроса; 0 — общее пространство задач.
+func UserID(ctx context.Context) int64 {
+	id, _ := ctx.Value(ctxKey{}).(int64)
+	return id
+}
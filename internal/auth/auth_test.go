@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setup задаёт секрет и общий пароль на время теста.
+func setup(t *testing.T, p string) {
+	t.Cleanup(func() {
+		SetSecret(nil)
+		SetPassword("")
+	})
+	SetSecret([]byte("secret"))
+	SetPassword(p)
+}
+
+func TestToken(t *testing.T) {
+	setup(t, "old")
+	now := time.Now()
+
+	t.Run("Valid", func(t *testing.T) {
+		token, err := Signin("old")
+		require.NoError(t, err)
+		c, err := Parse(token)
+		require.NoError(t, err)
+		assert.Zero(t, c.UserID)
+		assert.True(t, c.Matches("old"))
+
+		_, err = Signin("wrong")
+		assert.ErrorIs(t, err, ErrWrongPassword)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		token, err := NewToken(1, "hash", now.Add(-TokenTTL-time.Minute))
+		require.NoError(t, err)
+		_, err = Parse(token)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		token, err := NewToken(1, "hash", now)
+		require.NoError(t, err)
+		parts := strings.Split(token, ".")
+		// подмена uid в теле токена без новой подписи
+		other, err := NewToken(2, "hash", now)
+		require.NoError(t, err)
+		parts[1] = strings.Split(other, ".")[1]
+		_, err = Parse(strings.Join(parts, "."))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+
+		_, err = Parse(token[:len(token)-2])
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("PasswordChanged", func(t *testing.T) {
+		shared, err := Signin("old")
+		require.NoError(t, err)
+		user, err := NewToken(1, "hash-old", now)
+		require.NoError(t, err)
+
+		// пароль пользователя сменился — токен подписан верно, но выдан для прежнего
+		c, err := Parse(user)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), c.UserID)
+		assert.False(t, c.Matches("hash-new"))
+
+		// смена общего пароля меняет ключ подписи и отзывает все токены
+		SetPassword("new")
+		_, err = Parse(shared)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+		_, err = Parse(user)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
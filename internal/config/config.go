@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultPort   = 7540
+	DefaultDBFile = "scheduler.db"
+	DefaultWebDir = "./web"
+)
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// Config — итоговые настройки запуска. Источники в порядке приоритета:
+// флаги командной строки, переменные окружения, файл конфигурации, значения по умолчанию.
+type Config struct {
+	Port     int    `yaml:"port" toml:"port"`
+	DBFile   string `yaml:"dbfile" toml:"dbfile"`
+	DBURL    string `yaml:"db_url" toml:"db_url"`
+	Password string `yaml:"password" toml:"password"`
+	WebDir   string `yaml:"web_dir" toml:"web_dir"`
+	LogLevel string `yaml:"log_level" toml:"log_level"`
+	Timezone string `yaml:"timezone" toml:"timezone"`
+
+	// Location — загруженный часовой пояс Timezone.
+	Location *time.Location `yaml:"-" toml:"-"`
+	// Path — файл конфигурации, если он был указан.
+	Path string `yaml:"-" toml:"-"`
+
+	PrintConfig   bool `yaml:"-" toml:"-"`
+	MigrateDryRun bool `yaml:"-" toml:"-"`
+}
+
+func defaults() Config {
+	return Config{
+		Port:     DefaultPort,
+		DBFile:   DefaultDBFile,
+		WebDir:   DefaultWebDir,
+		LogLevel: "info",
+		Timezone: "Local",
+	}
+}
+
+// Load собирает конфигурацию из args (без имени программы) и окружения getenv.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet("todo", flag.ContinueOnError)
+	var flags Config
+	configPath := fs.String("config", "", "файл конфигурации YAML или TOML (TODO_CONFIG)")
+	fs.IntVar(&flags.Port, "port", 0, "порт веб-сервера (TODO_PORT)")
+	fs.StringVar(&flags.DBFile, "dbfile", "", "файл базы SQLite (TODO_DBFILE)")
+	fs.StringVar(&flags.DBURL, "db-url", "", "хранилище postgres://… или memory: (TODO_DB_URL)")
+	fs.StringVar(&flags.Password, "password", "", "пароль для входа (TODO_PASSWORD)")
+	fs.StringVar(&flags.WebDir, "web", "", "каталог фронтенда (TODO_WEB_DIR)")
+	fs.StringVar(&flags.LogLevel, "log-level", "", "уровень логирования: debug, info, warn, error (TODO_LOG_LEVEL)")
+	fs.StringVar(&flags.Timezone, "timezone", "", "часовой пояс, например Europe/Moscow (TODO_TZ)")
+	fs.BoolVar(&flags.PrintConfig, "print-config", false, "вывести итоговую конфигурацию и выйти")
+	fs.BoolVar(&flags.MigrateDryRun, "migrate-dry-run", false, "вывести непримененные миграции и выйти")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults()
+
+	cfg.Path = getenv("TODO_CONFIG")
+	if *configPath != "" {
+		cfg.Path = *configPath
+	}
+	if cfg.Path != "" {
+		if err := cfg.loadFile(cfg.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = flags.Port
+		case "dbfile":
+			cfg.DBFile = flags.DBFile
+		case "db-url":
+			cfg.DBURL = flags.DBURL
+		case "password":
+			cfg.Password = flags.Password
+		case "web":
+			cfg.WebDir = flags.WebDir
+		case "log-level":
+			cfg.LogLevel = flags.LogLevel
+		case "timezone":
+			cfg.Timezone = flags.Timezone
+		}
+	})
+	cfg.PrintConfig = flags.PrintConfig
+	cfg.MigrateDryRun = flags.MigrateDryRun
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать файл конфигурации: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("ошибка в файле конфигурации %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("ошибка в файле конфигурации %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("ошибка в файле конфигурации %s: неизвестный параметр %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("неизвестный формат файла конфигурации %s (ожидается .yaml, .yml или .toml)", path)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv(getenv func(string) string) error {
+	if v := getenv("TODO_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("неверное значение TODO_PORT: %q", v)
+		}
+		c.Port = port
+	}
+	for name, field := range map[string]*string{
+		"TODO_DBFILE":    &c.DBFile,
+		"TODO_DB_URL":    &c.DBURL,
+		"TODO_PASSWORD":  &c.Password,
+		"TODO_WEB_DIR":   &c.WebDir,
+		"TODO_LOG_LEVEL": &c.LogLevel,
+		"TODO_TZ":        &c.Timezone,
+	} {
+		if v := getenv(name); v != "" {
+			*field = v
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("порт должен быть в диапазоне 1-65535: %d", c.Port))
+	}
+	if c.DBURL == "" && strings.TrimSpace(c.DBFile) == "" {
+		errs = append(errs, errors.New("не указан файл базы данных"))
+	}
+	if info, err := os.Stat(c.WebDir); err != nil || !info.IsDir() {
+		errs = append(errs, fmt.Errorf("каталог фронтенда не найден: %s", c.WebDir))
+	}
+
+	c.LogLevel = strings.ToLower(c.LogLevel)
+	validLevel := false
+	for _, l := range logLevels {
+		validLevel = validLevel || c.LogLevel == l
+	}
+	if !validLevel {
+		errs = append(errs, fmt.Errorf("неизвестный уровень логирования %q (ожидается %s)", c.LogLevel, strings.Join(logLevels, ", ")))
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("неизвестный часовой пояс %q", c.Timezone))
+	}
+	c.Location = loc
+
+	if len(errs) > 0 {
+		return fmt.Errorf("ошибка конфигурации: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// dsnPassword — пароль в строке подключения lib/pq вида key=value,
+// в том числе в кавычках.
+var dsnPassword = regexp.MustCompile(`(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// Masked возвращает копию без секретов для вывода. В DBURL скрывается
+// пароль URL, параметр password и password= строки вида key=value.
+func (c Config) Masked() Config {
+	if c.Password != "" {
+		c.Password = "********"
+	}
+	if u, err := url.Parse(c.DBURL); err == nil && u.Scheme != "" {
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		c.DBURL = u.Redacted()
+	} else {
+		c.DBURL = dsnPassword.ReplaceAllString(c.DBURL, "${1}xxxxx")
+	}
+	return c
+}
+
+// Print выводит итоговую конфигурацию в формате YAML со скрытыми секретами.
+func (c Config) Print(w io.Writer) error {
+	if c.Path != "" {
+		fmt.Fprintf(w, "# файл конфигурации: %s\n", c.Path)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Masked()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
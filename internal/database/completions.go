@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+)
+
+// CompletionQuery — фильтр истории выполнений.
+type CompletionQuery struct {
+	// TaskID — только выполнения этой задачи; 0 — всех задач.
+	TaskID int64
+	// DateFrom и DateTo ограничивают дату выполненной задачи включительно,
+	// формат 20060102.
+	DateFrom string
+	DateTo   string
+	Limit    int
+}
+
+// prepareCompletionQuery проверяет фильтр и задаёт Limit по умолчанию.
+func prepareCompletionQuery(q *CompletionQuery) error {
+	var errs domain.FieldErrors
+	for _, f := range []struct{ field, value string }{{"date_from", q.DateFrom}, {"date_to", q.DateTo}} {
+		if f.value == "" {
+			continue
+		}
+		if fe := domain.ValidateDate(f.field, f.value); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if len(errs) > 0 {
+		return fieldsError(errs)
+	}
+	if q.Limit < 1 {
+		q.Limit = 50
+	}
+	return nil
+}
+
+// completionOf — запись о выполнении задачи task в момент now.
+func completionOf(task domain.Task, now time.Time) domain.Completion {
+	return domain.Completion{
+		TaskID:      task.ID,
+		Date:        task.Date,
+		CompletedAt: now.UTC().Format(createdFormat),
+		Title:       task.Title,
+		Comment:     task.Comment,
+		Repeat:      task.Repeat,
+		Version:     task.Version,
+	}
+}
+
+// reopened возвращает задачу, восстановленную по записи о выполнении c,
+// со следующей после выполнения версией.
+func reopened(c domain.Completion) (domain.Task, error) {
+	if c.Repeat != "" {
+		return domain.Task{}, ErrReopenRecurring
+	}
+	return domain.Task{ID: c.TaskID, Date: c.Date, Title: c.Title, Comment: c.Comment, Version: c.Version + 1}, nil
+}
+
+const completionColumns = "id, task_id, date, completed_at, title, comment, repeat, version"
+
+func scanCompletion(row interface{ Scan(...any) error }) (c domain.Completion, err error) {
+	err = row.Scan(&c.ID, &c.TaskID, &c.Date, &c.CompletedAt, &c.Title, &c.Comment, &c.Repeat, &c.Version)
+	return c, err
+}
+
+func (s *sqlStore) addCompletion(tx *sql.Tx, ownerID int64, c domain.Completion) error {
+	_, err := tx.Exec(
+		s.dialect.rebind("INSERT INTO task_completions (task_id, owner_id, date, completed_at, title, comment, repeat, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		c.TaskID, ownerID, c.Date, c.CompletedAt, c.Title, c.Comment, c.Repeat, c.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Completions(ownerID int64, q CompletionQuery) ([]domain.Completion, error) {
+	if err := prepareCompletionQuery(&q); err != nil {
+		return nil, err
+	}
+
+	where, args := []string{"owner_id = ?"}, []any{ownerID}
+	if q.TaskID != 0 {
+		where, args = append(where, "task_id = ?"), append(args, q.TaskID)
+	}
+	if q.DateFrom != "" {
+		where, args = append(where, "date >= ?"), append(args, q.DateFrom)
+	}
+	if q.DateTo != "" {
+		where, args = append(where, "date <= ?"), append(args, q.DateTo)
+	}
+	rows, err := s.db.Query(
+		s.dialect.rebind("SELECT "+completionColumns+" FROM task_completions WHERE "+strings.Join(where, " AND ")+" ORDER BY completed_at DESC, id DESC LIMIT ?"),
+		append(args, q.Limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database query error: %v", err)
+	}
+	defer rows.Close()
+
+	completions := make([]domain.Completion, 0)
+	for rows.Next() {
+		c, err := scanCompletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("database scan error: %v", err)
+		}
+		completions = append(completions, c)
+	}
+	return completions, rows.Err()
+}
+
+func (s *sqlStore) Reopen(ownerID, id int64) (domain.Task, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := s.get(tx, ownerID, id); err == nil {
+		return domain.Task{}, ErrNotCompleted
+	} else if !errors.Is(err, ErrTaskNotFound) {
+		return domain.Task{}, err
+	}
+
+	c, err := scanCompletion(tx.QueryRow(
+		s.dialect.rebind("SELECT "+completionColumns+" FROM task_completions WHERE task_id = ? AND owner_id = ? ORDER BY id DESC LIMIT 1"),
+		id, ownerID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Task{}, ErrCompletionNotFound
+	}
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	task, err := reopened(c)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	// задача возвращается под прежним id, чтобы история осталась связанной с ней
+	_, err = tx.Exec(
+		s.dialect.rebind("INSERT INTO scheduler (id, date, title, comment, repeat, owner_id, created_at, version) VALUES (?, ?, ?, ?, '', ?, ?, ?)"),
+		task.ID, task.Date, task.Title, task.Comment, ownerID, createdNow(), task.Version,
+	)
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	if _, err := tx.Exec(s.dialect.rebind("DELETE FROM task_completions WHERE id = ?"), c.ID); err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+
+	if task, err = s.get(tx, ownerID, id); err != nil {
+		return domain.Task{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	return task, nil
+}
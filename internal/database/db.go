@@ -4,244 +4,647 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Kovarniykrab/finishGolang/internal/domain"
-	"github.com/Kovarniykrab/finishGolang/internal/util"
-	_ "modernc.org/sqlite"
+	"github.com/Kovarniykrab/finishGolang/internal/query"
+	"github.com/jmoiron/sqlx"
 )
 
-const dbFile = "scheduler.db"
+type dialect int
 
-func InitDB() (*sql.DB, error) {
-	if os.Getenv("GO_TEST") == "1" {
-		os.Remove(dbFile)
+const (
+	sqliteDialect dialect = iota
+	postgresDialect
+)
+
+func (d dialect) String() string {
+	if d == postgresDialect {
+		return "postgres"
 	}
+	return "sqlite"
+}
 
-	db, err := sql.Open("sqlite", dbFile)
-	if err != nil {
-		return nil, fmt.Errorf("не удалось открыть БД: %v", err)
+// rebind переводит плейсхолдеры ? в формат диалекта.
+func (d dialect) rebind(query string) string {
+	if d == postgresDialect {
+		return sqlx.Rebind(sqlx.DOLLAR, query)
 	}
+	return query
+}
+
+// sqlStore реализует Store поверх database/sql; запросы пишутся с ? и
+// переводятся в диалект базы.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+	// fts — доступен полнотекстовый индекс scheduler_fts (только SQLite с FTS5).
+	fts bool
+}
+
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+const taskColumns = "id, date, title, comment, repeat, version, created_at, remaining, anchor, catch_up"
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
 
-	if err := createTable(db); err != nil {
-		return nil, fmt.Errorf("failed to create table: %v", err)
+func (s *sqlStore) get(q queryer, ownerID, id int64) (task domain.Task, err error) {
+	err = q.QueryRow(
+		s.dialect.rebind("SELECT "+taskColumns+" FROM scheduler WHERE id = ? AND owner_id = ?"),
+		id, ownerID,
+	).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Version, &task.CreatedAt, &task.Remaining, &task.Anchor, &task.CatchUp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return task, ErrTaskNotFound
 	}
+	return task, err
+}
+
+func (s *sqlStore) Get(ownerID, id int64) (domain.Task, error) {
+	return s.get(s.db, ownerID, id)
+}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("database connection failed: %v", err)
+// missing объясняет, почему запрос с условием на версию не затронул строку:
+// задачи нет или её версия уже другая.
+func (s *sqlStore) missing(q queryer, ownerID, id int64) error {
+	if _, err := s.get(q, ownerID, id); err != nil {
+		return err
 	}
+	return ErrVersionMismatch
+}
 
-	log.Println("База данных успешно инициализирована")
-	return db, nil
+// versionCond добавляет к запросу проверку версии, если она задана.
+func versionCond(query string, args []any, version int64) (string, []any) {
+	if version == 0 {
+		return query, args
+	}
+	return query + " AND version = ?", append(args, version)
 }
 
-func createTable(db *sql.DB) error {
-	query := `
-    CREATE TABLE IF NOT EXISTS scheduler (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        date TEXT NOT NULL,
-        title TEXT NOT NULL,
-        comment TEXT,
-        repeat TEXT
-    );
-    CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);`
+func (s *sqlStore) Delete(ownerID, id, version int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
 
-	_, err := db.Exec(query)
-	return err
+	ok, err := s.deleteTask(tx, ownerID, id, version)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.missing(tx, ownerID, id)
+	}
+	return tx.Commit()
 }
 
-func GetTaskStory(db *sql.DB, id int64) (task domain.Task, err error) {
-	err = db.QueryRow(
-		"SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?",
-		id,
-	).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+// deleteTask удаляет задачу вместе с её исключениями из повторений и
+// сообщает, нашлась ли задача с такой версией.
+func (s *sqlStore) deleteTask(tx *sql.Tx, ownerID, id, version int64) (bool, error) {
+	query, args := versionCond("DELETE FROM scheduler WHERE id = ? AND owner_id = ?", []any{id, ownerID}, version)
+	result, err := tx.Exec(s.dialect.rebind(query), args...)
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	} else if n == 0 {
+		return false, nil
+	}
 
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		return task, err
-	case err != nil:
-		return task, err
+	if _, err := tx.Exec(s.dialect.rebind("DELETE FROM task_exceptions WHERE task_id = ? AND owner_id = ?"), id, ownerID); err != nil {
+		return false, fmt.Errorf("database error: %w", err)
 	}
+	return true, nil
+}
 
-	fmt.Println("db", task)
+// update записывает задачу и возвращает её с новой версией и временем создания.
+// Счётчик remaining сохраняется, пока не изменилось правило повторения,
+// иначе начинается заново.
+func (s *sqlStore) update(q queryer, ownerID int64, task domain.Task, version int64) (domain.Task, error) {
+	query, args := versionCond(
+		"UPDATE scheduler SET date = ?, title = ?, comment = ?, anchor = ?, catch_up = ?, "+
+			"remaining = CASE WHEN repeat = ? THEN remaining ELSE ? END, repeat = ?, "+
+			"version = version + 1 WHERE id = ? AND owner_id = ?",
+		[]any{task.Date, task.Title, task.Comment, task.Anchor, task.CatchUp, task.Repeat, seriesCount(task.Repeat), task.Repeat, task.ID, ownerID},
+		version,
+	)
 
+	err := q.QueryRow(s.dialect.rebind(query+" RETURNING version, created_at, remaining"), args...).Scan(&task.Version, &task.CreatedAt, &task.Remaining)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Task{}, s.missing(q, ownerID, task.ID)
+	}
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
 	return task, nil
+}
 
+func (s *sqlStore) Update(ownerID int64, task domain.Task, version int64) (domain.Task, error) {
+	task, err := prepare(task)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	return s.update(s.db, ownerID, task, version)
 }
 
-func DeleteTaskStory(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM scheduler WHERE id = ?", id)
+func (s *sqlStore) Patch(ownerID, id int64, patch domain.TaskPatch, version int64) (domain.Task, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	current, err := s.get(tx, ownerID, id)
 	if err != nil {
-		return err
+		return domain.Task{}, err
+	}
+	if err := checkVersion(current, version); err != nil {
+		return domain.Task{}, err
+	}
 
+	task, err := applyPatch(current, patch)
+	if err != nil {
+		return domain.Task{}, err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("task not found")
+	task, err = s.update(tx, ownerID, task, current.Version)
+	if err != nil {
+		return domain.Task{}, err
 	}
-	return nil
 
+	if err := tx.Commit(); err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	return task, nil
 }
 
-func UpdateTaskStory(db *sql.DB, newValues domain.Task, id int64) (domain.Task, error) {
-	if newValues.Date != "" {
-		if len(newValues.Date) != 8 {
-			return domain.Task{}, errors.New("invalid date format (expected YYYYMMDD)")
-		}
+func (s *sqlStore) Complete(ownerID, id int64, now time.Time, version int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
 
-		_, err := time.Parse("20060102", newValues.Date)
-		if err != nil {
-			return domain.Task{}, errors.New("invalid date (does not exist)")
-		}
+	task, err := s.get(tx, ownerID, id)
+	if err != nil {
+		return err
 	}
-	if newValues.Title != "" {
-		newValues.Title = strings.TrimSpace(newValues.Title)
-		if newValues.Title == "" {
-			return domain.Task{}, errors.New("title cannot be empty")
-		}
-		if len(newValues.Title) > 100 {
-			return domain.Task{}, errors.New("title is too long (max 100 chars)")
-		}
+	if err := checkVersion(task, version); err != nil {
+		return err
 	}
 
-	if newValues.Comment != "" && len(newValues.Comment) > 500 {
-		return domain.Task{}, errors.New("comment is too long (max 500 chars)")
+	exdates, err := s.exdates(tx, ownerID, id)
+	if err != nil {
+		return err
+	}
+	done, err := nextAfterDone(task, exdates, now)
+	if err != nil {
+		return err
 	}
 
-	if newValues.Repeat != "" {
-		now := time.Now().UTC()
-		date := newValues.Date
-		if date == "" {
-			// Если дата не указана, получаем текущую дату из БД
-			var currentDate string
-			err := db.QueryRow("SELECT date FROM scheduler WHERE id = ?", id).Scan(&currentDate)
-			if err != nil {
-				return domain.Task{}, fmt.Errorf("failed to get current date: %w", err)
-			}
-			date = currentDate
+	if !done.keep {
+		// серия закончилась — вместе с ней удаляются её исключения
+		if ok, err := s.deleteTask(tx, ownerID, id, task.Version); err != nil {
+			return err
+		} else if !ok {
+			return ErrVersionMismatch
+		}
+	} else {
+		result, err := tx.Exec(
+			s.dialect.rebind("UPDATE scheduler SET date = ?, remaining = ?, version = version + 1 WHERE id = ? AND owner_id = ? AND version = ?"),
+			done.next.Date, done.next.Remaining, id, ownerID, task.Version,
+		)
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
 		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("database error: %w", err)
+		} else if n == 0 {
+			return ErrVersionMismatch
+		}
+	}
 
-		if _, err := util.NextDate(now, date, newValues.Repeat); err != nil {
-			return domain.Task{}, fmt.Errorf("invalid repeat rule: %w", err)
+	for _, missed := range done.missed {
+		if _, err := s.insert(tx, ownerID, missed); err != nil {
+			return err
 		}
 	}
+	if err := s.addCompletion(tx, ownerID, completionOf(task, now)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlStore) Skip(ownerID, id int64, now time.Time, version int64) (domain.Task, error) {
+	return s.reschedule(ownerID, id, version, domain.EventSkip, func(task domain.Task, exdates []string) (domain.Task, error) {
+		return nextAfterSkip(task, exdates, now)
+	})
+}
+
+func (s *sqlStore) Snooze(ownerID, id int64, snooze Snooze, now time.Time, version int64) (domain.Task, error) {
+	return s.reschedule(ownerID, id, version, domain.EventSnooze, func(task domain.Task, _ []string) (domain.Task, error) {
+		return snoozed(task, snooze, now)
+	})
+}
+
+// reschedule переносит задачу на дату, которую вычисляет move, и записывает
+// событие action в историю в той же транзакции. move получает исключения задачи.
+func (s *sqlStore) reschedule(ownerID, id, version int64, action string, move func(domain.Task, []string) (domain.Task, error)) (domain.Task, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := s.get(tx, ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := checkVersion(current, version); err != nil {
+		return domain.Task{}, err
+	}
 
-	query := "UPDATE scheduler SET "
-	var args []interface{}
-	var updates []string
+	exdates, err := s.exdates(tx, ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	task, err := move(current, exdates)
+	if err != nil {
+		return domain.Task{}, err
+	}
 
-	if newValues.Date != "" {
-		updates = append(updates, "date = ?")
-		args = append(args, newValues.Date)
+	err = tx.QueryRow(
+		s.dialect.rebind("UPDATE scheduler SET date = ?, remaining = ?, version = version + 1 WHERE id = ? AND owner_id = ? AND version = ? RETURNING version"),
+		task.Date, task.Remaining, id, ownerID, current.Version,
+	).Scan(&task.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Task{}, ErrVersionMismatch
+	}
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
 	}
-	if newValues.Title != "" {
-		updates = append(updates, "title = ?")
-		args = append(args, newValues.Title)
+
+	_, err = tx.Exec(
+		s.dialect.rebind("INSERT INTO task_history (task_id, owner_id, action, from_date, to_date, created_at) VALUES (?, ?, ?, ?, ?, ?)"),
+		id, ownerID, action, current.Date, task.Date, createdNow(),
+	)
+	if err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
 	}
-	if newValues.Comment != "" {
-		updates = append(updates, "comment = ?")
-		args = append(args, newValues.Comment)
+
+	if err := tx.Commit(); err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
 	}
-	if newValues.Repeat != "" {
-		updates = append(updates, "repeat = ?")
-		args = append(args, newValues.Repeat)
+	return task, nil
+}
+
+func (s *sqlStore) History(ownerID, id int64) ([]domain.TaskEvent, error) {
+	rows, err := s.db.Query(
+		s.dialect.rebind("SELECT task_id, action, from_date, to_date, created_at FROM task_history WHERE owner_id = ? AND task_id = ? ORDER BY id"),
+		ownerID, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("database query error: %v", err)
 	}
+	defer rows.Close()
 
-	if len(updates) == 0 {
-		return domain.Task{}, errors.New("nothing to update")
+	events := make([]domain.TaskEvent, 0)
+	for rows.Next() {
+		var e domain.TaskEvent
+		if err := rows.Scan(&e.TaskID, &e.Action, &e.From, &e.To, &e.At); err != nil {
+			return nil, fmt.Errorf("database scan error: %v", err)
+		}
+		events = append(events, e)
 	}
+	return events, rows.Err()
+}
+
+func (s *sqlStore) PatchOccurrence(ownerID, id int64, date, scope string, patch domain.TaskPatch, now time.Time, version int64) (domain.Task, error) {
+	return s.changeOccurrence(ownerID, id, date, scope, &patch, now, version)
+}
 
-	query += strings.Join(updates, ", ") + " WHERE id = ?"
-	args = append(args, id)
+func (s *sqlStore) DeleteOccurrence(ownerID, id int64, date, scope string, now time.Time, version int64) error {
+	_, err := s.changeOccurrence(ownerID, id, date, scope, nil, now, version)
+	return err
+}
 
-	_, err := db.Exec(query, args...)
+// changeOccurrence выполняет planOccurrence в одной транзакции.
+func (s *sqlStore) changeOccurrence(ownerID, id int64, date, scope string, patch *domain.TaskPatch, now time.Time, version int64) (domain.Task, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return domain.Task{}, fmt.Errorf("database error: %w", err)
 	}
+	defer tx.Rollback()
+
+	task, err := s.get(tx, ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := checkVersion(task, version); err != nil {
+		return domain.Task{}, err
+	}
+	exdates, err := s.exdates(tx, ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
 
-	var task domain.Task
-	err = db.QueryRow(
-		"SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?",
-		id,
-	).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	plan, err := planOccurrence(task, exdates, date, scope, patch, now)
 	if err != nil {
-		return domain.Task{}, fmt.Errorf("failed to get updated task: %w", err)
+		return domain.Task{}, err
 	}
 
-	return task, nil
+	var result domain.Task
+	if plan.insert != nil {
+		newID, err := s.insert(tx, ownerID, *plan.insert)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		if result, err = s.get(tx, ownerID, newID); err != nil {
+			return domain.Task{}, err
+		}
+	}
+
+	var query string
+	var args []any
+	switch {
+	case plan.exception != nil:
+		query = "INSERT INTO task_exceptions (task_id, owner_id, date, override_id) VALUES (?, ?, ?, ?)"
+		args = []any{id, ownerID, plan.exception.Date, result.ID}
+	case plan.split != "" && plan.insert != nil:
+		query = "UPDATE task_exceptions SET task_id = ? WHERE task_id = ? AND owner_id = ? AND date >= ?"
+		args = []any{result.ID, id, ownerID, plan.split}
+	case plan.split != "" || !plan.keep:
+		query = "DELETE FROM task_exceptions WHERE task_id = ? AND owner_id = ? AND date >= ?"
+		args = []any{id, ownerID, plan.split}
+	}
+	if query != "" {
+		if _, err := tx.Exec(s.dialect.rebind(query), args...); err != nil {
+			return domain.Task{}, fmt.Errorf("database error: %w", err)
+		}
+	}
+
+	switch {
+	case !plan.keep:
+		if ok, err := s.deleteTask(tx, ownerID, id, task.Version); err != nil {
+			return domain.Task{}, err
+		} else if !ok {
+			return domain.Task{}, ErrVersionMismatch
+		}
+	case plan.moved:
+		err := tx.QueryRow(
+			s.dialect.rebind("UPDATE scheduler SET date = ?, remaining = ?, version = version + 1 WHERE id = ? AND owner_id = ? AND version = ? RETURNING version"),
+			plan.series.Date, plan.series.Remaining, id, ownerID, task.Version,
+		).Scan(&plan.series.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Task{}, ErrVersionMismatch
+		}
+		if err != nil {
+			return domain.Task{}, fmt.Errorf("database error: %w", err)
+		}
+	default:
+		if plan.series, err = s.update(tx, ownerID, plan.series, task.Version); err != nil {
+			return domain.Task{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return domain.Task{}, fmt.Errorf("database error: %w", err)
+	}
+	if plan.insert == nil {
+		result = plan.series
+	}
+	return result, nil
+}
+
+// exdates возвращает даты исключений задачи.
+func (s *sqlStore) exdates(q queryer, ownerID, id int64) ([]string, error) {
+	exceptions, err := s.exceptions(q, ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+	dates := make([]string, len(exceptions))
+	for i, e := range exceptions {
+		dates[i] = e.Date
+	}
+	return dates, nil
 }
 
-func AddTaskStory(db *sql.DB, task domain.Task) (int64, error) {
-	result, err := db.Exec(
-		"INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)",
-		task.Date, task.Title, task.Comment, task.Repeat,
+func (s *sqlStore) exceptions(q queryer, ownerID, id int64) ([]domain.TaskException, error) {
+	rows, err := q.Query(
+		s.dialect.rebind("SELECT task_id, date, override_id FROM task_exceptions WHERE task_id = ? AND owner_id = ? ORDER BY date"),
+		id, ownerID,
 	)
 	if err != nil {
-		return 0, fmt.Errorf("database error: %w", err)
+		return nil, fmt.Errorf("database query error: %v", err)
+	}
+	defer rows.Close()
+
+	exceptions := make([]domain.TaskException, 0)
+	for rows.Next() {
+		var e domain.TaskException
+		if err := rows.Scan(&e.TaskID, &e.Date, &e.OverrideID); err != nil {
+			return nil, fmt.Errorf("database scan error: %v", err)
+		}
+		exceptions = append(exceptions, e)
+	}
+	return exceptions, rows.Err()
+}
+
+func (s *sqlStore) Exceptions(ownerID, id int64) ([]domain.TaskException, error) {
+	if _, err := s.get(s.db, ownerID, id); err != nil {
+		return nil, err
+	}
+	return s.exceptions(s.db, ownerID, id)
+}
+
+func (s *sqlStore) Add(ownerID int64, task domain.Task) (int64, error) {
+	task, err := prepare(task)
+	if err != nil {
+		return 0, err
 	}
 
-	id, err := result.LastInsertId()
+	return s.insert(s.db, ownerID, task)
+}
+
+// insert добавляет проверенную задачу и возвращает её id.
+func (s *sqlStore) insert(q queryer, ownerID int64, task domain.Task) (int64, error) {
+	var id int64
+	err := q.QueryRow(
+		s.dialect.rebind("INSERT INTO scheduler (date, title, comment, repeat, owner_id, created_at, remaining, anchor, catch_up) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id"),
+		task.Date, task.Title, task.Comment, task.Repeat, ownerID, createdNow(), seriesCount(task.Repeat), task.Anchor, task.CatchUp,
+	).Scan(&id)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get task ID: %w", err)
+		return 0, fmt.Errorf("database error: %w", err)
 	}
 
 	return id, nil
 }
 
-func GetTasksStory(db *sql.DB, search string, limit int) ([]*domain.Task, error) {
-	query, args := buildQuery(search, limit)
-	rows, err := db.Query(query, args...)
+func (s *sqlStore) List(ownerID int64, q TaskQuery) (TaskPage, error) {
+	after, err := prepareQuery(&q, s.fts)
 	if err != nil {
-		return nil, fmt.Errorf("database query error: %v", err)
+		return TaskPage{}, err
+	}
+
+	source, where, args := taskFilter(ownerID, q)
+	var total int
+	err = s.db.QueryRow(s.dialect.rebind("SELECT COUNT(*) FROM "+source+" WHERE "+strings.Join(where, " AND ")), args...).Scan(&total)
+	if err != nil {
+		if q.FullText && isFTSQueryError(err, q.Search) {
+			return TaskPage{}, validationError("invalid full-text query: %v", err)
+		}
+		return TaskPage{}, fmt.Errorf("database query error: %v", err)
+	}
+
+	query, args := buildQuery(ownerID, q, after)
+	rows, err := s.db.Query(s.dialect.rebind(query), args...)
+	if err != nil {
+		return TaskPage{}, fmt.Errorf("database query error: %v", err)
 	}
 	defer rows.Close()
 
 	tasks := make([]*domain.Task, 0)
+	ranks := make(map[int64]float64)
+	found := make(map[int64]Snippet)
 	for rows.Next() {
 		var t domain.Task
-		if err := rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
-			return nil, fmt.Errorf("row scan error: %v", err)
+		dest := []any{&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat, &t.Version, &t.CreatedAt, &t.Remaining, &t.Anchor, &t.CatchUp}
+		var rank float64
+		var snippet string
+		if q.FullText {
+			dest = append(dest, &rank, &snippet)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return TaskPage{}, fmt.Errorf("row scan error: %v", err)
+		}
+		if q.FullText {
+			ranks[t.ID] = rank
+			found[t.ID] = parseSnippet(snippet)
 		}
 		tasks = append(tasks, &t)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %v", err)
+		return TaskPage{}, fmt.Errorf("rows error: %v", err)
+	}
+
+	tasks, next := page(tasks, q, func(t *domain.Task) string {
+		if q.Sort == SortRank {
+			return strconv.FormatFloat(ranks[t.ID], 'g', -1, 64)
+		}
+		return sortKey(t, q.Sort)
+	})
+
+	var snippets map[int64]Snippet
+	if q.FullText {
+		snippets = make(map[int64]Snippet, len(tasks))
+		for _, t := range tasks {
+			snippets[t.ID] = found[t.ID]
+		}
+	}
+	return TaskPage{Tasks: tasks, NextCursor: next, Total: total, Snippets: snippets}, nil
+}
+
+// taskFilter возвращает источник строк и условия WHERE для фильтров выборки,
+// без курсора. При полнотекстовом поиске источник — ftsSource.
+func taskFilter(ownerID int64, q TaskQuery) (string, []string, []any) {
+	source := "scheduler"
+	where := []string{"owner_id = ?"}
+	var args []any
+
+	if q.FullText {
+		source = ftsSource
+		args = append(args, q.Search)
 	}
+	args = append(args, ownerID)
 
-	return tasks, nil
+	if q.Search != "" && !q.FullText {
+		cond, sargs := query.Text{Value: q.Search}.SQL()
+		where = append(where, cond)
+		args = append(args, sargs...)
+	}
+	if q.DateFrom != "" {
+		where = append(where, "date >= ?")
+		args = append(args, q.DateFrom)
+	}
+	if q.DateTo != "" {
+		where = append(where, "date <= ?")
+		args = append(args, q.DateTo)
+	}
+	if q.HasRepeat != nil {
+		if *q.HasRepeat {
+			where = append(where, "repeat <> ''")
+		} else {
+			where = append(where, "repeat = ''")
+		}
+	}
+	if q.RepeatType != "" {
+		cond, rargs := query.Repeat{Kind: q.RepeatType}.SQL()
+		where = append(where, cond)
+		args = append(args, rargs...)
+	}
+	if q.Filter != nil {
+		cond, fargs := q.Filter.SQL()
+		where = append(where, cond)
+		args = append(args, fargs...)
+	}
+	return source, where, args
 }
 
-func buildQuery(search string, limit int) (string, []interface{}) {
-	baseQuery := "SELECT id, date, title, comment, repeat FROM scheduler"
-	where := []string{}
-	args := []interface{}{}
+// buildQuery строит выборку страницы: фильтры, условие курсора и limit+1 строк,
+// чтобы узнать, есть ли следующая страница. Столбец сортировки берётся только
+// из sortColumns.
+func buildQuery(ownerID int64, q TaskQuery, after *cursor) (string, []any) {
+	source, where, args := taskFilter(ownerID, q)
+
+	column := sortColumns[q.Sort]
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
 
-	if search != "" {
-		if t, err := time.Parse("02.01.2006", search); err == nil {
-			where = append(where, "date = ?")
-			args = append(args, t.Format("20060102"))
+	if after != nil {
+		var key any = after.Key
+		if column == "rank" {
+			key, _ = strconv.ParseFloat(after.Key, 64)
+		}
+		if column == "id" {
+			where = append(where, "id "+op+" ?")
+			args = append(args, after.ID)
 		} else {
-			searchTerm := "%" + search + "%"
-			where = append(where, "(title LIKE ? OR comment LIKE ?)")
-			args = append(args, searchTerm, searchTerm)
+			where = append(where, "("+column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?))")
+			args = append(args, key, key, after.ID)
 		}
 	}
 
-	if len(where) > 0 {
-		baseQuery += " WHERE " + strings.Join(where, " AND ")
+	columns := taskColumns
+	if q.FullText {
+		columns += ", rank, snippet"
+	}
+	query := "SELECT " + columns + " FROM " + source + " WHERE " + strings.Join(where, " AND ")
+	if column == "id" {
+		query += " ORDER BY id " + dir
+	} else {
+		query += " ORDER BY " + column + " " + dir + ", id " + dir
 	}
+	query += " LIMIT ?"
+	args = append(args, q.Limit+1)
 
-	baseQuery += " ORDER BY date ASC LIMIT ?"
-	args = append(args, limit)
+	return query, args
+}
 
-	return baseQuery, args
+// isUniqueViolation распознаёт нарушение уникальности в SQLite и PostgreSQL.
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "duplicate key value violates unique constraint")
 }
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+)
+
+// Виды ошибок хранилища; API отображает их в коды 404, 400, 409 и 412.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrValidation   = errors.New("validation failed")
+	ErrConflict     = errors.New("conflict")
+	ErrPrecondition = errors.New("precondition failed")
+)
+
+// Error — ошибка хранилища с сообщением для клиента. errors.Is(err, Kind) истинно.
+type Error struct {
+	Kind error
+	Msg  string
+	// Fields — ошибки отдельных полей для ErrValidation.
+	Fields domain.FieldErrors
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+var (
+	ErrTaskNotFound = &Error{Kind: ErrNotFound, Msg: "task not found"}
+	ErrUserNotFound = &Error{Kind: ErrNotFound, Msg: "user not found"}
+	ErrLoginTaken   = &Error{Kind: ErrConflict, Msg: "login already taken"}
+	// ErrVersionMismatch — задача изменилась после того, как клиент её прочитал.
+	ErrVersionMismatch = &Error{Kind: ErrPrecondition, Msg: "task was modified by another request"}
+	// ErrNoNextOccurrence — серия повторений закончилась, пропускать нечего.
+	ErrNoNextOccurrence   = &Error{Kind: ErrConflict, Msg: "series has no next occurrence"}
+	ErrCompletionNotFound = &Error{Kind: ErrNotFound, Msg: "completion not found"}
+	// ErrNotCompleted — задача существует, открывать заново нечего.
+	ErrNotCompleted = &Error{Kind: ErrConflict, Msg: "task is not completed"}
+	// ErrReopenRecurring — заново открыть можно только разовую задачу.
+	ErrReopenRecurring = &Error{Kind: ErrConflict, Msg: "only one-off tasks can be reopened"}
+)
+
+func validationError(format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Msg: fmt.Sprintf(format, args...)}
+}
+
+func fieldsError(fields domain.FieldErrors) error {
+	return &Error{Kind: ErrValidation, Msg: fields.Error(), Fields: fields}
+}
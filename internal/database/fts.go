@@ -0,0 +1,73 @@
+package database
+
+import (
+	"strings"
+)
+
+// Границы совпадений во фрагменте snippet(); parseSnippet превращает их в
+// интервалы, чтобы текст задачи не отдавался клиенту как разметка.
+const (
+	snippetOpen  = '\x02'
+	snippetClose = '\x03'
+)
+
+// ftsSource — выборка задач, подходящих под запрос MATCH, с релевантностью
+// (bm25: чем меньше, тем лучше) и фрагментом текста с границами совпадений.
+const ftsSource = `(SELECT scheduler.*, bm25(scheduler_fts) AS rank,
+    snippet(scheduler_fts, -1, char(2), char(3), '…', 12) AS snippet
+    FROM scheduler JOIN scheduler_fts ON scheduler_fts.rowid = scheduler.id
+    WHERE scheduler_fts MATCH ?) AS found`
+
+// isMissingModule сообщает, что SQLite собран без модуля виртуальных таблиц
+// (FTS5), нужного миграции.
+func isMissingModule(err error) bool {
+	return strings.Contains(err.Error(), "no such module")
+}
+
+// parseSnippet убирает границы совпадений из фрагмента raw и возвращает их
+// позиции в символах.
+func parseSnippet(raw string) Snippet {
+	var (
+		text  strings.Builder
+		marks [][2]int
+		n     int
+		open  = -1
+	)
+	for _, r := range raw {
+		switch {
+		case r == snippetOpen && open < 0:
+			open = n
+		case r == snippetClose && open >= 0:
+			if n > open {
+				marks = append(marks, [2]int{open, n})
+			}
+			open = -1
+		case r == snippetOpen || r == snippetClose:
+		default:
+			text.WriteRune(r)
+			n++
+		}
+	}
+	return Snippet{Text: text.String(), Highlights: marks}
+}
+
+// ftsQueryErrors — сообщения FTS5 об ошибке в выражении MATCH.
+var ftsQueryErrors = []string{"fts5: syntax error", "unterminated string", "unknown special query"}
+
+// isFTSQueryError распознаёт ошибку разбора пользовательского запроса MATCH.
+// "no such column" FTS5 возвращает для неизвестного префикса столбца
+// ("foo:bar"); та же ошибка из-за схемы базы ошибкой запроса не считается.
+func isFTSQueryError(err error, search string) bool {
+	msg := err.Error()
+	for _, s := range ftsQueryErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	_, column, ok := strings.Cut(msg, "no such column: ")
+	if !ok {
+		return false
+	}
+	column, _, _ = strings.Cut(column, " ")
+	return column != "" && strings.Contains(search, column)
+}
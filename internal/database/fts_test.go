@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteFullText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	s, err := OpenSQLite(path)
+	require.NoError(t, err)
+	require.True(t, s.(*sqlStore).fts)
+
+	ids := map[string]int64{}
+	for _, task := range []domain.Task{
+		{Date: "20240126", Title: "Купить молоко", Comment: "и хлеб"},
+		{Date: "20240127", Title: "Молочная ферма"},
+		{Date: "20240128", Title: "Позвонить маме", Comment: "спросить про молоко"},
+		{Date: "20240129", Title: "Бассейн"},
+	} {
+		id, err := s.Add(1, task)
+		require.NoError(t, err)
+		ids[task.Title] = id
+	}
+	_, err = s.Add(2, domain.Task{Date: "20240126", Title: "Чужое молоко"})
+	require.NoError(t, err)
+
+	search := func(t *testing.T, query string, limit int) ([]string, TaskPage) {
+		var titles []string
+		var first TaskPage
+		q := TaskQuery{Search: query, FullText: true, Limit: limit}
+		for {
+			page, err := s.List(1, q)
+			require.NoError(t, err)
+			if q.Cursor == "" {
+				first = page
+			}
+			for _, task := range page.Tasks {
+				titles = append(titles, task.Title)
+			}
+			if page.NextCursor == "" {
+				return titles, first
+			}
+			q.Cursor = page.NextCursor
+		}
+	}
+
+	t.Run("Syntax", func(t *testing.T) {
+		titles, page := search(t, "МОЛОКО", 50)
+		// совпадение в заголовке релевантнее, чем в длинном комментарии
+		assert.Equal(t, []string{"Купить молоко", "Позвонить маме"}, titles)
+		assert.Equal(t, 2, page.Total)
+		assert.Equal(t, Snippet{Text: "Купить молоко", Highlights: [][2]int{{7, 13}}}, page.Snippets[ids["Купить молоко"]])
+
+		titles, _ = search(t, "мол*", 1)
+		assert.ElementsMatch(t, []string{"Купить молоко", "Молочная ферма", "Позвонить маме"}, titles)
+
+		titles, _ = search(t, `"купить молоко"`, 50)
+		assert.Equal(t, []string{"Купить молоко"}, titles)
+
+		titles, _ = search(t, "молоко NOT хлеб", 50)
+		assert.Equal(t, []string{"Позвонить маме"}, titles)
+
+		titles, _ = search(t, "молоко OR бассейн", 50)
+		assert.Len(t, titles, 3)
+
+		for _, bad := range []string{`"молоко`, "молоко)", "foo:молоко", "*"} {
+			_, err := s.List(1, TaskQuery{Search: bad, FullText: true, Limit: 10})
+			assert.ErrorIs(t, err, ErrValidation, bad)
+		}
+		_, err := s.List(1, TaskQuery{Search: "молоко", Sort: SortRank, Limit: 10})
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		id := ids["Бассейн"]
+		task, err := s.Get(1, id)
+		require.NoError(t, err)
+		task.Title = "Плавание"
+		_, err = s.Update(1, task, 0)
+		require.NoError(t, err)
+
+		titles, _ := search(t, "бассейн", 50)
+		assert.Empty(t, titles)
+		titles, _ = search(t, "плавание", 50)
+		assert.Equal(t, []string{"Плавание"}, titles)
+
+		require.NoError(t, s.Delete(1, id, 0))
+		titles, _ = search(t, "плавание", 50)
+		assert.Empty(t, titles)
+	})
+
+	t.Run("SnippetIsText", func(t *testing.T) {
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: `<img src=x onerror="alert(1)"> кефир`})
+		require.NoError(t, err)
+
+		_, page := search(t, "кефир", 50)
+		snippet := page.Snippets[id]
+		assert.Equal(t, `<img src=x onerror="alert(1)"> кефир`, snippet.Text)
+		assert.Equal(t, [][2]int{{31, 36}}, snippet.Highlights)
+		require.NoError(t, s.Delete(1, id, 0))
+	})
+
+	t.Run("Rebuild", func(t *testing.T) {
+		// база, созданная до появления индекса, индексируется миграцией
+		require.NoError(t, s.Close())
+		db, err := sql.Open("sqlite", path)
+		require.NoError(t, err)
+		_, err = db.Exec(`DROP TRIGGER scheduler_fts_ai; DROP TRIGGER scheduler_fts_ad;
+			DROP TRIGGER scheduler_fts_au; DROP TABLE scheduler_fts;
+			DELETE FROM schema_version WHERE name = '0012_task_fts'`)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		s, err = OpenSQLite(path)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		require.True(t, s.(*sqlStore).fts)
+		titles, _ := search(t, "молоко", 50)
+		assert.Len(t, titles, 2)
+	})
+}
+
+func TestParseSnippet(t *testing.T) {
+	for _, tt := range []struct {
+		raw  string
+		want Snippet
+	}{
+		{"без совпадений", Snippet{Text: "без совпадений"}},
+		{"\x02мол\x03око и \x02хлеб\x03", Snippet{Text: "молоко и хлеб", Highlights: [][2]int{{0, 3}, {9, 13}}}},
+		{"…купить \x02молоко\x03…", Snippet{Text: "…купить молоко…", Highlights: [][2]int{{8, 14}}}},
+		// лишние и пустые границы отбрасываются
+		{"\x03a\x02\x02b\x03\x02\x03", Snippet{Text: "ab", Highlights: [][2]int{{1, 2}}}},
+		{"<b>\x02x\x03</b>", Snippet{Text: "<b>x</b>", Highlights: [][2]int{{3, 4}}}},
+	} {
+		assert.Equal(t, tt.want, parseSnippet(tt.raw), "%q", tt.raw)
+	}
+}
+
+func TestIsFTSQueryError(t *testing.T) {
+	for _, tt := range []struct {
+		msg, search string
+		want        bool
+	}{
+		{`SQL logic error: fts5: syntax error near "AND" (1)`, "AND", true},
+		{"SQL logic error: unterminated string (1)", `"молоко`, true},
+		{"SQL logic error: unknown special query:  (1)", "*", true},
+		{"SQL logic error: no such column: foo (1)", "foo:bar", true},
+		// ошибки схемы и прочие SQLITE_ERROR — не ошибки запроса
+		{"SQL logic error: no such column: remaining (1)", "молоко", false},
+		{"SQL logic error: no such table: scheduler_fts (1)", "молоко", false},
+		{"SQL logic error: near \"WHERE\": syntax error (1)", "молоко", false},
+	} {
+		assert.Equal(t, tt.want, isFTSQueryError(errors.New(tt.msg), tt.search), tt.msg)
+	}
+}
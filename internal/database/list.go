@@ -0,0 +1,209 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/query"
+)
+
+// Поля сортировки списка задач.
+const (
+	SortDate    = "date"
+	SortTitle   = "title"
+	SortID      = "id"
+	SortCreated = "created"
+	// SortRank — по релевантности, только для полнотекстового поиска.
+	SortRank = "rank"
+)
+
+// sortColumns — допустимые поля сортировки и соответствующие им столбцы.
+var sortColumns = map[string]string{
+	SortDate:    "date",
+	SortTitle:   "title",
+	SortID:      "id",
+	SortCreated: "created_at",
+	SortRank:    "rank",
+}
+
+// repeatTypes — виды правил повторения для фильтра RepeatType.
+var repeatTypes = []string{"d", "w", "m", "mw", "y"}
+
+// TaskQuery — параметры выборки списка задач.
+type TaskQuery struct {
+	// Search — дата, диапазон или относительное выражение (query.ParsePeriod),
+	// иначе текст для поиска.
+	Search string
+	// Now — текущее время в часовом поясе пользователя, от него считаются
+	// «today», «next 7 days» и т. п.; нулевое значение — time.Now().
+	Now time.Time
+	// FullText включает полнотекстовый поиск по Search: префиксы (слово*),
+	// фразы в кавычках, AND/OR/NOT. Если хранилище его не поддерживает,
+	// Search ищется как подстрока.
+	FullText bool
+	// DateFrom и DateTo ограничивают дату задачи включительно, формат 20060102.
+	DateFrom string
+	DateTo   string
+	// HasRepeat: nil — все задачи, true — только повторяющиеся, false — только разовые.
+	HasRepeat *bool
+	// RepeatType — вид правила повторения: d, w, m, mw или y.
+	RepeatType string
+	// Filter — условие на языке поиска (query.Parse), nil — без условия.
+	Filter query.Expr
+	// Sort — одно из Sort*; по умолчанию SortRank при полнотекстовом поиске,
+	// иначе SortDate. При равенстве порядок задаёт id.
+	Sort string
+	Desc bool
+	// Cursor — NextCursor предыдущей страницы; сортировка должна совпадать.
+	Cursor string
+	Limit  int
+}
+
+// TaskPage — страница списка задач.
+type TaskPage struct {
+	Tasks []*domain.Task
+	// NextCursor пуст на последней странице.
+	NextCursor string
+	// Total — число задач под фильтрами без учёта страниц.
+	Total int
+	// Snippets — фрагменты текста по id задачи, только при полнотекстовом поиске.
+	Snippets map[int64]Snippet
+}
+
+// Snippet — фрагмент заголовка или комментария с совпадениями поиска. Text —
+// исходный текст задачи без разметки, Highlights — интервалы совпадений
+// [начало, конец) в символах Text.
+type Snippet struct {
+	Text       string
+	Highlights [][2]int
+}
+
+// cursor — позиция в списке: ключ сортировки и id последней выданной задачи.
+type cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k,omitempty"`
+	ID   int64  `json:"i"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, validationError("invalid cursor")
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, validationError("invalid cursor")
+	}
+	return &c, nil
+}
+
+// sortKey возвращает значение поля сортировки задачи.
+func sortKey(task *domain.Task, sort string) string {
+	switch sort {
+	case SortTitle:
+		return task.Title
+	case SortCreated:
+		return task.CreatedAt
+	case SortID:
+		return ""
+	default:
+		return task.Date
+	}
+}
+
+// prepareQuery проверяет параметры выборки, подставляет значения по умолчанию
+// и разбирает курсор (nil — первая страница). fts сообщает, поддерживает ли
+// хранилище полнотекстовый поиск; после вызова q.FullText истинно, только
+// если он действительно будет выполняться.
+//
+// Дата из Search переносится в DateFrom/DateTo (пересечением с ними), и в
+// Search остаётся только текст.
+func prepareQuery(q *TaskQuery, fts bool) (*cursor, error) {
+	var errs domain.FieldErrors
+
+	for _, f := range []struct{ field, value string }{{"date_from", q.DateFrom}, {"date_to", q.DateTo}} {
+		if f.value == "" {
+			continue
+		}
+		if fe := domain.ValidateDate(f.field, f.value); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+	if period, ok := query.ParsePeriod(q.Search, q.Now); ok {
+		if period.From != "" && period.To != "" && period.From > period.To {
+			errs = append(errs, domain.FieldError{Field: "search", Code: domain.CodeInvalidDate, Message: "search date range ends before it starts"})
+		}
+		period = period.Intersect(query.Period{From: q.DateFrom, To: q.DateTo})
+		q.Search, q.DateFrom, q.DateTo = "", period.From, period.To
+	}
+
+	requested := q.FullText
+	q.FullText = q.FullText && fts && q.Search != ""
+	switch {
+	case q.Sort == "" && q.FullText:
+		q.Sort = SortRank
+	case q.Sort == "":
+		q.Sort = SortDate
+	case q.Sort == SortRank && !requested:
+		errs = append(errs, domain.FieldError{Field: "sort", Code: domain.CodeFormat, Message: "sort=rank requires full-text search"})
+	case q.Sort == SortRank && !q.FullText:
+		q.Sort = SortDate
+	}
+
+	if _, ok := sortColumns[q.Sort]; !ok {
+		errs = append(errs, domain.FieldError{Field: "sort", Code: domain.CodeFormat, Message: "sort must be one of date, title, id, created, rank"})
+	}
+	if q.RepeatType != "" && !slices.Contains(repeatTypes, q.RepeatType) {
+		errs = append(errs, domain.FieldError{Field: "repeat", Code: domain.CodeFormat, Message: "repeat must be one of " + strings.Join(repeatTypes, ", ")})
+	}
+	if q.Limit < 1 {
+		errs = append(errs, domain.FieldError{Field: "limit", Code: domain.CodeFormat, Message: "limit must be positive"})
+	}
+	if len(errs) > 0 {
+		return nil, fieldsError(errs)
+	}
+
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	c, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, validationError("cursor does not match sort order")
+	}
+	if c.Sort == SortRank {
+		if _, err := strconv.ParseFloat(c.Key, 64); err != nil {
+			return nil, validationError("invalid cursor")
+		}
+	}
+	return c, nil
+}
+
+// page обрезает выборку из limit+1 задач до limit и формирует курсор следующей
+// страницы; key возвращает значение поля сортировки задачи.
+func page(tasks []*domain.Task, q TaskQuery, key func(*domain.Task) string) ([]*domain.Task, string) {
+	if len(tasks) <= q.Limit {
+		return tasks, ""
+	}
+	tasks = tasks[:q.Limit]
+	last := tasks[len(tasks)-1]
+	next := cursor{Sort: q.Sort, Desc: q.Desc, Key: key(last), ID: last.ID}
+	return tasks, next.encode()
+}
@@ -0,0 +1,498 @@
+package database
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/query"
+)
+
+type memoryTask struct {
+	domain.Task
+	ownerID int64
+}
+
+type memoryCompletion struct {
+	domain.Completion
+	ownerID int64
+}
+
+type memoryEvent struct {
+	domain.TaskEvent
+	ownerID int64
+}
+
+// memoryStore хранит задачи в памяти процесса; используется в тестах.
+type memoryStore struct {
+	mu     sync.Mutex
+	tasks  map[int64]memoryTask
+	events []memoryEvent
+	// exceptions — исключения по id задачи.
+	exceptions   map[int64][]domain.TaskException
+	completions  []memoryCompletion
+	completionID int64
+	users        map[int64]domain.User
+	nextID       int64
+	userID       int64
+	secret       []byte
+}
+
+func NewMemory() Store {
+	return &memoryStore{
+		tasks:      make(map[int64]memoryTask),
+		exceptions: make(map[int64][]domain.TaskException),
+		users:      make(map[int64]domain.User),
+	}
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
+
+func (m *memoryStore) get(ownerID, id int64) (domain.Task, error) {
+	t, ok := m.tasks[id]
+	if !ok || t.ownerID != ownerID {
+		return domain.Task{}, ErrTaskNotFound
+	}
+	return t.Task, nil
+}
+
+func (m *memoryStore) Add(ownerID int64, task domain.Task) (int64, error) {
+	task, err := prepare(task)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.add(ownerID, task).ID, nil
+}
+
+// add сохраняет проверенную задачу под новым id.
+func (m *memoryStore) add(ownerID int64, task domain.Task) domain.Task {
+	m.nextID++
+	task.ID = m.nextID
+	task.Version = 1
+	task.CreatedAt = createdNow()
+	task.Remaining = seriesCount(task.Repeat)
+	m.tasks[task.ID] = memoryTask{Task: task, ownerID: ownerID}
+	return task
+}
+
+func (m *memoryStore) Get(ownerID, id int64) (domain.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.get(ownerID, id)
+}
+
+func (m *memoryStore) Update(ownerID int64, task domain.Task, version int64) (domain.Task, error) {
+	task, err := prepare(task)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.get(ownerID, task.ID)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := checkVersion(current, version); err != nil {
+		return domain.Task{}, err
+	}
+	task.Version = current.Version + 1
+	task.CreatedAt = current.CreatedAt
+	task.Remaining = current.Remaining
+	if task.Repeat != current.Repeat {
+		task.Remaining = seriesCount(task.Repeat)
+	}
+	m.tasks[task.ID] = memoryTask{Task: task, ownerID: ownerID}
+	return task, nil
+}
+
+func (m *memoryStore) Patch(ownerID, id int64, patch domain.TaskPatch, version int64) (domain.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.get(ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := checkVersion(current, version); err != nil {
+		return domain.Task{}, err
+	}
+
+	task, err := applyPatch(current, patch)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	task.Version++
+	if task.Repeat != current.Repeat {
+		task.Remaining = seriesCount(task.Repeat)
+	}
+
+	m.tasks[id] = memoryTask{Task: task, ownerID: ownerID}
+	return task, nil
+}
+
+func (m *memoryStore) Delete(ownerID, id, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, err := m.get(ownerID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(task, version); err != nil {
+		return err
+	}
+	m.delete(id)
+	return nil
+}
+
+// delete удаляет задачу вместе с её исключениями из повторений.
+func (m *memoryStore) delete(id int64) {
+	delete(m.tasks, id)
+	delete(m.exceptions, id)
+}
+
+func (m *memoryStore) List(ownerID int64, q TaskQuery) (TaskPage, error) {
+	after, err := prepareQuery(&q, false)
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	period := query.Period{From: q.DateFrom, To: q.DateTo}
+	tasks := make([]*domain.Task, 0)
+	for _, t := range m.tasks {
+		if t.ownerID != ownerID {
+			continue
+		}
+		if q.Search != "" && !(query.Text{Value: q.Search}).Match(t.Task) {
+			continue
+		}
+		if !period.Contains(t.Date) {
+			continue
+		}
+		if q.HasRepeat != nil && *q.HasRepeat != (t.Repeat != "") {
+			continue
+		}
+		if q.RepeatType != "" && !(query.Repeat{Kind: q.RepeatType}).Match(t.Task) {
+			continue
+		}
+		if q.Filter != nil && !q.Filter.Match(t.Task) {
+			continue
+		}
+		task := t.Task
+		tasks = append(tasks, &task)
+	}
+	total := len(tasks)
+
+	// less сравнивает задачи в порядке выдачи: ключ сортировки, затем id.
+	less := func(a *domain.Task, key string, id int64) bool {
+		if k := sortKey(a, q.Sort); k != key {
+			return k < key != q.Desc
+		}
+		if a.ID == id {
+			return false
+		}
+		return a.ID < id != q.Desc
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return less(tasks[i], sortKey(tasks[j], q.Sort), tasks[j].ID)
+	})
+	if after != nil {
+		i := 0
+		for i < len(tasks) && (less(tasks[i], after.Key, after.ID) || tasks[i].ID == after.ID) {
+			i++
+		}
+		tasks = tasks[i:]
+	}
+	if len(tasks) > q.Limit+1 {
+		tasks = tasks[:q.Limit+1]
+	}
+
+	tasks, next := page(tasks, q, func(t *domain.Task) string { return sortKey(t, q.Sort) })
+	return TaskPage{Tasks: tasks, NextCursor: next, Total: total}, nil
+}
+
+func (m *memoryStore) Complete(ownerID, id int64, now time.Time, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, err := m.get(ownerID, id)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(task, version); err != nil {
+		return err
+	}
+
+	done, err := nextAfterDone(task, m.exdates(id), now)
+	if err != nil {
+		return err
+	}
+
+	for _, missed := range done.missed {
+		m.add(ownerID, missed)
+	}
+	m.completionID++
+	c := completionOf(task, now)
+	c.ID = m.completionID
+	m.completions = append(m.completions, memoryCompletion{Completion: c, ownerID: ownerID})
+
+	if !done.keep {
+		m.delete(id)
+		return nil
+	}
+	done.next.Version++
+	m.tasks[id] = memoryTask{Task: done.next, ownerID: ownerID}
+	return nil
+}
+
+func (m *memoryStore) Skip(ownerID, id int64, now time.Time, version int64) (domain.Task, error) {
+	return m.reschedule(ownerID, id, version, domain.EventSkip, func(task domain.Task) (domain.Task, error) {
+		return nextAfterSkip(task, m.exdates(id), now)
+	})
+}
+
+func (m *memoryStore) Snooze(ownerID, id int64, s Snooze, now time.Time, version int64) (domain.Task, error) {
+	return m.reschedule(ownerID, id, version, domain.EventSnooze, func(task domain.Task) (domain.Task, error) {
+		return snoozed(task, s, now)
+	})
+}
+
+func (m *memoryStore) reschedule(ownerID, id, version int64, action string, move func(domain.Task) (domain.Task, error)) (domain.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, err := m.get(ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := checkVersion(current, version); err != nil {
+		return domain.Task{}, err
+	}
+
+	task, err := move(current)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	task.Version++
+	m.tasks[id] = memoryTask{Task: task, ownerID: ownerID}
+	m.events = append(m.events, memoryEvent{
+		TaskEvent: domain.TaskEvent{TaskID: id, Action: action, From: current.Date, To: task.Date, At: createdNow()},
+		ownerID:   ownerID,
+	})
+	return task, nil
+}
+
+func (m *memoryStore) History(ownerID, id int64) ([]domain.TaskEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := make([]domain.TaskEvent, 0)
+	for _, e := range m.events {
+		if e.ownerID == ownerID && e.TaskID == id {
+			events = append(events, e.TaskEvent)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryStore) PatchOccurrence(ownerID, id int64, date, scope string, patch domain.TaskPatch, now time.Time, version int64) (domain.Task, error) {
+	return m.changeOccurrence(ownerID, id, date, scope, &patch, now, version)
+}
+
+func (m *memoryStore) DeleteOccurrence(ownerID, id int64, date, scope string, now time.Time, version int64) error {
+	_, err := m.changeOccurrence(ownerID, id, date, scope, nil, now, version)
+	return err
+}
+
+func (m *memoryStore) changeOccurrence(ownerID, id int64, date, scope string, patch *domain.TaskPatch, now time.Time, version int64) (domain.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, err := m.get(ownerID, id)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if err := checkVersion(task, version); err != nil {
+		return domain.Task{}, err
+	}
+
+	plan, err := planOccurrence(task, m.exdates(id), date, scope, patch, now)
+	if err != nil {
+		return domain.Task{}, err
+	}
+
+	var result domain.Task
+	if plan.insert != nil {
+		result = m.add(ownerID, *plan.insert)
+	}
+
+	switch {
+	case plan.exception != nil:
+		plan.exception.OverrideID = result.ID
+		m.exceptions[id] = append(m.exceptions[id], *plan.exception)
+	case plan.split != "" || !plan.keep:
+		var kept []domain.TaskException
+		for _, e := range m.exceptions[id] {
+			switch {
+			case e.Date < plan.split:
+				kept = append(kept, e)
+			case plan.insert != nil:
+				e.TaskID = result.ID
+				m.exceptions[result.ID] = append(m.exceptions[result.ID], e)
+			}
+		}
+		m.exceptions[id] = kept
+	}
+
+	if plan.keep {
+		plan.series.Version++
+		if !plan.moved && plan.series.Repeat != task.Repeat {
+			plan.series.Remaining = seriesCount(plan.series.Repeat)
+		}
+		m.tasks[id] = memoryTask{Task: plan.series, ownerID: ownerID}
+	} else {
+		m.delete(id)
+	}
+
+	if plan.insert == nil {
+		result = plan.series
+	}
+	return result, nil
+}
+
+// exdates возвращает даты исключений задачи id.
+func (m *memoryStore) exdates(id int64) []string {
+	var dates []string
+	for _, e := range m.exceptions[id] {
+		dates = append(dates, e.Date)
+	}
+	return dates
+}
+
+func (m *memoryStore) Exceptions(ownerID, id int64) ([]domain.TaskException, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.get(ownerID, id); err != nil {
+		return nil, err
+	}
+	exceptions := slices.Clone(m.exceptions[id])
+	if exceptions == nil {
+		exceptions = make([]domain.TaskException, 0)
+	}
+	slices.SortFunc(exceptions, func(a, b domain.TaskException) int { return strings.Compare(a.Date, b.Date) })
+	return exceptions, nil
+}
+
+func (m *memoryStore) Completions(ownerID int64, q CompletionQuery) ([]domain.Completion, error) {
+	if err := prepareCompletionQuery(&q); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	completions := make([]domain.Completion, 0)
+	for i := len(m.completions) - 1; i >= 0 && len(completions) < q.Limit; i-- {
+		c := m.completions[i]
+		if c.ownerID != ownerID ||
+			q.TaskID != 0 && c.TaskID != q.TaskID ||
+			q.DateFrom != "" && c.Date < q.DateFrom ||
+			q.DateTo != "" && c.Date > q.DateTo {
+			continue
+		}
+		completions = append(completions, c.Completion)
+	}
+	return completions, nil
+}
+
+func (m *memoryStore) Reopen(ownerID, id int64) (domain.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.get(ownerID, id); err == nil {
+		return domain.Task{}, ErrNotCompleted
+	}
+
+	for i := len(m.completions) - 1; i >= 0; i-- {
+		c := m.completions[i]
+		if c.ownerID != ownerID || c.TaskID != id {
+			continue
+		}
+		task, err := reopened(c.Completion)
+		if err != nil {
+			return domain.Task{}, err
+		}
+		task.CreatedAt = createdNow()
+		m.tasks[id] = memoryTask{Task: task, ownerID: ownerID}
+		m.completions = slices.Delete(m.completions, i, i+1)
+		return task, nil
+	}
+	return domain.Task{}, ErrCompletionNotFound
+}
+
+func (m *memoryStore) CreateUser(login, passwordHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Login == login {
+			return 0, ErrLoginTaken
+		}
+	}
+	m.userID++
+	m.users[m.userID] = domain.User{ID: m.userID, Login: login, PasswordHash: passwordHash}
+	return m.userID, nil
+}
+
+func (m *memoryStore) UserByLogin(login string) (domain.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+	return domain.User{}, ErrUserNotFound
+}
+
+func (m *memoryStore) UserByID(id int64) (domain.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return domain.User{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (m *memoryStore) AuthSecret() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.secret == nil {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		m.secret = secret
+	}
+	return m.secret, nil
+}
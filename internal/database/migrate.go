@@ -0,0 +1,233 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+type migrator struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// load читает встроенные файлы вида 0001_name.sql по возрастанию версии.
+func (m migrator) load() ([]Migration, error) {
+	dir := path.Join("migrations", m.dialect.String())
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".sql")
+		num, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name: %s", e.Name())
+		}
+		version, err := strconv.Atoi(num)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration version: %s", e.Name())
+		}
+		body, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, mg := range migrations {
+		if mg.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential: %s", mg.Name)
+		}
+	}
+	return migrations, nil
+}
+
+func (m migrator) tableExists(name string) (bool, error) {
+	query := "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+	if m.dialect == postgresDialect {
+		query = "SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ?"
+	}
+	var n int
+	err := m.db.QueryRow(m.dialect.rebind(query), name).Scan(&n)
+	return n > 0, err
+}
+
+func (m migrator) columnExists(table, column string) (bool, error) {
+	var n int
+	err := m.db.QueryRow(
+		"SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", table, column,
+	).Scan(&n)
+	return n > 0, err
+}
+
+// legacyVersion определяет версию схемы SQLite-базы, созданной до появления schema_version.
+func (m migrator) legacyVersion() (int, error) {
+	if m.dialect != sqliteDialect {
+		return 0, nil
+	}
+
+	ok, err := m.tableExists("scheduler")
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	ok, err = m.columnExists("scheduler", "owner_id")
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 1, nil
+	}
+	ok, err = m.tableExists("users")
+	if err != nil || !ok {
+		return 1, err
+	}
+	return 2, nil
+}
+
+// version возвращает текущую версию схемы базы.
+func (m migrator) version() (int, error) {
+	ok, err := m.tableExists("schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return m.legacyVersion()
+	}
+
+	var version sql.NullInt64
+	if err := m.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// state отказывает в работе с базой, созданной более новой версией программы.
+func (m migrator) state() ([]Migration, int, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	current, err := m.version()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return nil, 0, fmt.Errorf("%w: version %d, supported %d", ErrSchemaTooNew, current, len(migrations))
+	}
+	return migrations, current, nil
+}
+
+// migrate применяет недостающие миграции, каждую в своей транзакции.
+// Базы без schema_version получают записи для уже имеющихся версий.
+func (m migrator) migrate() error {
+	migrations, current, err := m.state()
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TEXT NOT NULL
+    )`); err != nil {
+		return fmt.Errorf("failed to create schema_version: %w", err)
+	}
+
+	for _, mg := range migrations[:current] {
+		if _, err := m.db.Exec(m.dialect.rebind(
+			"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING"),
+			mg.Version, mg.Name, time.Now().UTC().Format(time.RFC3339),
+		); err != nil {
+			return fmt.Errorf("failed to record version %d: %w", mg.Version, err)
+		}
+	}
+
+	for _, mg := range migrations[current:] {
+		if err := m.apply(mg); err != nil {
+			return fmt.Errorf("migration %s failed: %w", mg.Name, err)
+		}
+		log.Printf("Применена миграция %s", mg.Name)
+	}
+	return nil
+}
+
+// apply применяет миграцию в транзакции. Если SQLite собран без FTS5,
+// миграция полнотекстового индекса записывается как применённая без него:
+// поиск тогда работает через LIKE.
+func (m migrator) apply(mg Migration) error {
+	err := m.exec(mg)
+	if err == nil || m.dialect != sqliteDialect || !isMissingModule(err) {
+		return err
+	}
+	log.Printf("Миграция %s пропущена: %v", mg.Name, err)
+	_, err = m.db.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		mg.Version, mg.Name, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (m migrator) exec(mg Migration) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(mg.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(m.dialect.rebind(
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)"),
+		mg.Version, mg.Name, time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// printPending выводит миграции, которые будут применены, не изменяя базу.
+func (m migrator) printPending(w io.Writer) error {
+	migrations, current, err := m.state()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Текущая версия схемы: %d\n", current)
+	if current == len(migrations) {
+		fmt.Fprintln(w, "Нет миграций для применения")
+		return nil
+	}
+	printMigrations(w, migrations[current:])
+	return nil
+}
+
+func printMigrations(w io.Writer, migrations []Migration) {
+	for _, mg := range migrations {
+		fmt.Fprintf(w, "-- %s\n%s\n", mg.Name, strings.TrimSpace(mg.SQL))
+	}
+}
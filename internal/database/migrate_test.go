@@ -0,0 +1,244 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// migrationSQL возвращает текст встроенных SQLite-миграций с 1 по version.
+func migrationSQL(t *testing.T, version int) string {
+	migrations, err := migrator{dialect: sqliteDialect}.load()
+	require.NoError(t, err)
+	var b strings.Builder
+	for _, mg := range migrations[:version] {
+		b.WriteString(mg.SQL)
+	}
+	return b.String()
+}
+
+func TestMigrator(t *testing.T) {
+	migrations, err := migrator{dialect: sqliteDialect}.load()
+	require.NoError(t, err)
+	latest := len(migrations)
+
+	// versioned создаёт базу версии version с заполненной schema_version
+	versioned := func(version int) func(t *testing.T, db *sql.DB) {
+		return func(t *testing.T, db *sql.DB) {
+			_, err := db.Exec(migrationSQL(t, version))
+			require.NoError(t, err)
+			_, err = db.Exec("CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL)")
+			require.NoError(t, err)
+			for _, mg := range migrations[:version] {
+				_, err = db.Exec("INSERT INTO schema_version VALUES (?, ?, '')", mg.Version, mg.Name)
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	// legacyKept проверяет, что задачи старой базы пережили миграцию
+	legacyKept := func(t *testing.T, m migrator) {
+		var title string
+		require.NoError(t, m.db.QueryRow("SELECT title FROM scheduler").Scan(&title))
+		assert.Equal(t, "Старая", title)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, db *sql.DB)
+		// before — версия схемы до миграции, after — после неё
+		before, after int
+		err           error
+		// pending — миграции, которые выводит пробный запуск
+		pending []string
+		check   func(t *testing.T, m migrator)
+	}{
+		{
+			name:    "Empty",
+			setup:   func(t *testing.T, db *sql.DB) {},
+			after:   latest,
+			pending: []string{"0001_init", "0002_users"},
+		},
+		{
+			name: "LegacyInit",
+			setup: func(t *testing.T, db *sql.DB) {
+				_, err := db.Exec(migrationSQL(t, 1) + "INSERT INTO scheduler (date, title) VALUES ('20240126', 'Старая');")
+				require.NoError(t, err)
+			},
+			before:  1,
+			after:   latest,
+			pending: []string{"0002_users", "0003_task_version"},
+			check:   legacyKept,
+		},
+		{
+			name: "LegacyUsers",
+			setup: func(t *testing.T, db *sql.DB) {
+				_, err := db.Exec(migrationSQL(t, 2) + "INSERT INTO scheduler (date, title) VALUES ('20240126', 'Старая');")
+				require.NoError(t, err)
+			},
+			before:  2,
+			after:   latest,
+			pending: []string{"0003_task_version"},
+			check:   legacyKept,
+		},
+		{
+			name:   "Current",
+			setup:  versioned(latest),
+			before: latest,
+			after:  latest,
+		},
+		{
+			name: "TooNew",
+			setup: func(t *testing.T, db *sql.DB) {
+				versioned(latest)(t, db)
+				_, err := db.Exec("INSERT INTO schema_version VALUES (?, 'future', '')", latest+1)
+				require.NoError(t, err)
+			},
+			before: latest + 1,
+			after:  latest + 1,
+			err:    ErrSchemaTooNew,
+		},
+		{
+			// 0004 сначала добавляет колонку, затем падает на существующем индексе
+			name: "PartialFailure",
+			setup: func(t *testing.T, db *sql.DB) {
+				versioned(3)(t, db)
+				_, err := db.Exec("CREATE INDEX idx_owner_title ON scheduler(owner_id, title)")
+				require.NoError(t, err)
+			},
+			before:  3,
+			after:   3,
+			pending: []string{"0004_task_created"},
+			check: func(t *testing.T, m migrator) {
+				// ALTER TABLE из той же миграции откатился вместе с ней
+				ok, err := m.columnExists("scheduler", "created_at")
+				require.NoError(t, err)
+				assert.False(t, ok)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scheduler.db")
+			db, err := sql.Open("sqlite", path)
+			require.NoError(t, err)
+			defer db.Close()
+			tt.setup(t, db)
+			m := migrator{db: db, dialect: sqliteDialect}
+
+			version, err := m.version()
+			require.NoError(t, err)
+			assert.Equal(t, tt.before, version)
+
+			var out bytes.Buffer
+			err = printPendingSQLite(path, &out)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.Contains(t, out.String(), "Текущая версия схемы: ")
+				for _, name := range tt.pending {
+					assert.Contains(t, out.String(), "-- "+name+"\n")
+				}
+				for _, mg := range migrations[:tt.before] {
+					assert.NotContains(t, out.String(), "-- "+mg.Name+"\n")
+				}
+				if tt.before == latest {
+					assert.Contains(t, out.String(), "Нет миграций для применения")
+				}
+			}
+			// пробный запуск не создаёт schema_version у старых баз
+			ok, err := m.tableExists("schema_version")
+			require.NoError(t, err)
+			assert.Equal(t, tt.before > 2, ok)
+
+			err = m.migrate()
+			switch {
+			case tt.err != nil:
+				assert.ErrorIs(t, err, tt.err)
+			case tt.after < latest:
+				assert.ErrorContains(t, err, migrations[tt.after].Name)
+			default:
+				assert.NoError(t, err)
+			}
+
+			version, err = m.version()
+			require.NoError(t, err)
+			assert.Equal(t, tt.after, version)
+
+			var recorded int
+			require.NoError(t, db.QueryRow("SELECT count(*) FROM schema_version").Scan(&recorded))
+			assert.Equal(t, tt.after, recorded)
+
+			if tt.check != nil {
+				tt.check(t, m)
+			}
+		})
+	}
+}
+
+func TestMigrateMissingModule(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	m := migrator{db: db, dialect: sqliteDialect}
+	require.NoError(t, m.migrate())
+	latest, err := m.version()
+	require.NoError(t, err)
+
+	// сборка без модуля: миграция записывается, но таблица не создаётся
+	require.NoError(t, m.apply(Migration{Version: latest + 1, Name: "missing", SQL: "CREATE VIRTUAL TABLE missing USING nosuch(a);"}))
+	version, err := m.version()
+	require.NoError(t, err)
+	assert.Equal(t, latest+1, version)
+	ok, err := m.tableExists("missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.Error(t, m.apply(Migration{Version: latest + 2, Name: "broken", SQL: "CREATE TABLE scheduler (id INTEGER);"}))
+}
+
+func TestPrintPendingSQLiteMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	t.Setenv("GO_TEST", "1")
+
+	var out bytes.Buffer
+	require.NoError(t, printPendingSQLite(path, &out))
+	assert.Contains(t, out.String(), "не найдена")
+	assert.Contains(t, out.String(), "-- 0001_init\n")
+	_, err := os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPrintPendingSQLiteReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = db.Exec(migrationSQL(t, 1) + "INSERT INTO scheduler (date, title) VALUES ('20240126', 'Старая');")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+	t.Setenv("GO_TEST", "1")
+
+	var out bytes.Buffer
+	require.NoError(t, printPendingSQLite(path, &out))
+	assert.Contains(t, out.String(), "Текущая версия схемы: 1\n")
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	ro, err := openSQLiteDB("file:" + path + "?mode=ro")
+	require.NoError(t, err)
+	defer ro.Close()
+	_, err = ro.Exec("DELETE FROM scheduler")
+	assert.Error(t, err)
+}
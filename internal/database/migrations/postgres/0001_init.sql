@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS scheduler (
+    id BIGSERIAL PRIMARY KEY,
+    date TEXT NOT NULL,
+    title TEXT NOT NULL,
+    comment TEXT,
+    repeat TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
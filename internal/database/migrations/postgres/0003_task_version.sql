@@ -0,0 +1 @@
+ALTER TABLE scheduler ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+ALTER TABLE scheduler ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_owner_title ON scheduler(owner_id, title);
@@ -0,0 +1 @@
+ALTER TABLE scheduler ADD COLUMN catch_up TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+CREATE TABLE task_exceptions (
+    task_id BIGINT NOT NULL,
+    owner_id BIGINT NOT NULL,
+    date TEXT NOT NULL,
+    override_id BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (task_id, date)
+);
@@ -0,0 +1 @@
+ALTER TABLE task_completions ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
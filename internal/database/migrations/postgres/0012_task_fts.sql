@@ -0,0 +1,3 @@
+-- Полнотекстовый индекс есть только в SQLite (FTS5); миграция сохраняет
+-- одинаковую нумерацию версий.
+SELECT 1;
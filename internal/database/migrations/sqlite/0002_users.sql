@@ -0,0 +1,11 @@
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    login TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL
+);
+CREATE TABLE settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+ALTER TABLE scheduler ADD COLUMN owner_id INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX idx_owner_date ON scheduler(owner_id, date);
@@ -0,0 +1 @@
+ALTER TABLE scheduler ADD COLUMN anchor TEXT NOT NULL DEFAULT '';
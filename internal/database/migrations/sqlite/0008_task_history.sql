@@ -0,0 +1,10 @@
+CREATE TABLE task_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    owner_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    from_date TEXT NOT NULL,
+    to_date TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+CREATE INDEX idx_history_task ON task_history(owner_id, task_id);
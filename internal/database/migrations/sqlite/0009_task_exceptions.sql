@@ -0,0 +1,7 @@
+CREATE TABLE task_exceptions (
+    task_id INTEGER NOT NULL,
+    owner_id INTEGER NOT NULL,
+    date TEXT NOT NULL,
+    override_id INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (task_id, date)
+);
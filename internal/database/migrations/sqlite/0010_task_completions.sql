@@ -0,0 +1,12 @@
+CREATE TABLE task_completions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    owner_id INTEGER NOT NULL,
+    date TEXT NOT NULL,
+    completed_at TEXT NOT NULL,
+    title TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    repeat TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_completions_owner ON task_completions(owner_id, completed_at);
+CREATE INDEX idx_completions_task ON task_completions(owner_id, task_id);
@@ -0,0 +1 @@
+ALTER TABLE task_completions ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,21 @@
+-- Полнотекстовый индекс заголовков и комментариев. Таблица scheduler_fts
+-- хранит только индекс (content='scheduler'), триггеры держат его в
+-- синхронизации с scheduler. Токенизатор unicode61 приводит кириллицу к
+-- нижнему регистру. IF NOT EXISTS — для баз, где индекс уже создавался при
+-- запуске, до этой миграции.
+CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(
+    title, comment,
+    content='scheduler', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_ai AFTER INSERT ON scheduler BEGIN
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_ad AFTER DELETE ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+END;
+CREATE TRIGGER IF NOT EXISTS scheduler_fts_au AFTER UPDATE OF title, comment ON scheduler BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', old.id, old.title, old.comment);
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (new.id, new.title, new.comment);
+END;
+INSERT INTO scheduler_fts(scheduler_fts) VALUES ('rebuild');
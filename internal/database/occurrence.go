@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// occurrencePlan — изменения хранилища при правке одного повторения задачи
+// или повторений начиная с него. Хранилища выполняют его в одной транзакции.
+type occurrencePlan struct {
+	// series — серия после изменения; keep false — серию нужно удалить.
+	series domain.Task
+	keep   bool
+	// moved — у серии изменились только дата и Remaining (ScopeOccurrence),
+	// иначе она записывается целиком.
+	moved bool
+	// insert — разовая задача, заменяющая повторение, или новая серия.
+	insert *domain.Task
+	// exception — исключение для ScopeOccurrence; OverrideID заполняет
+	// хранилище после добавления insert.
+	exception *domain.TaskException
+	// split — дата начала новой серии (ScopeFollowing): исключения с этой
+	// даты переходят к новой серии, а при удалении удаляются.
+	split string
+}
+
+var errNotOccurrence = fieldsError(domain.FieldErrors{{
+	Field: "occurrence", Code: domain.CodeInvalidDate, Message: "occurrence is not a date of the task",
+}})
+
+// planOccurrence готовит изменение повторения date задачи task в границах
+// scope; patch nil — удаление. exdates — уже существующие исключения задачи.
+func planOccurrence(task domain.Task, exdates []string, date, scope string, patch *domain.TaskPatch, now time.Time) (occurrencePlan, error) {
+	if task.Repeat == "" {
+		return occurrencePlan{}, validationError("scope %s requires a recurring task", scope)
+	}
+	if date == "" {
+		date = task.Date
+	}
+	if fe := domain.ValidateDate("occurrence", date); fe != nil {
+		return occurrencePlan{}, fieldsError(domain.FieldErrors{*fe})
+	}
+	k, err := occurrenceIndex(task, exdates, date)
+	if err != nil {
+		return occurrencePlan{}, err
+	}
+
+	plan := occurrencePlan{series: task, keep: true}
+	switch scope {
+	case domain.ScopeOccurrence:
+		plan.exception = &domain.TaskException{TaskID: task.ID, Date: date}
+		plan.moved = true
+		if patch != nil {
+			if patch.Repeat != nil || patch.Anchor != nil || patch.CatchUp != nil {
+				return occurrencePlan{}, validationError("only date, title and comment can be changed for one occurrence")
+			}
+			one, err := applyPatch(domain.Task{Date: date, Title: task.Title, Comment: task.Comment}, *patch)
+			if err != nil {
+				return occurrencePlan{}, err
+			}
+			plan.insert = &one
+		}
+		if k == 0 {
+			// текущее повторение заменено или отменено — серия переходит к следующему
+			next, err := nextAfterSkip(task, append(slices.Clone(exdates), date), now)
+			if errors.Is(err, ErrNoNextOccurrence) {
+				plan.keep = false
+			} else if err != nil {
+				return occurrencePlan{}, err
+			}
+			plan.series = next
+		}
+
+	case domain.ScopeFollowing:
+		if k == 0 {
+			// с текущего повторения — это вся серия
+			if patch == nil {
+				plan.keep = false
+				return plan, nil
+			}
+			plan.series, err = applyPatch(task, *patch)
+			return plan, err
+		}
+
+		// старая серия заканчивается накануне date, новая начинается с date
+		// и получает оставшиеся повторения
+		rule, err := util.ParseRule(task.Repeat)
+		if err != nil {
+			return occurrencePlan{}, err
+		}
+		day, _ := time.Parse(util.DateFormat, date)
+		plan.series.Repeat = rule.WithLimits(util.Limits{Until: day.AddDate(0, 0, -1).Format(util.DateFormat)}).String()
+		if plan.series, err = prepare(plan.series); err != nil {
+			return occurrencePlan{}, err
+		}
+		plan.split = date
+
+		if patch != nil {
+			limits := rule.Limits
+			if limits.Count > 0 {
+				limits.Count = task.Remaining - k
+			}
+			next := task
+			next.ID = 0
+			next.Date = date
+			next.Repeat = rule.WithLimits(limits).String()
+			if next, err = applyPatch(next, *patch); err != nil {
+				return occurrencePlan{}, err
+			}
+			plan.insert = &next
+		}
+
+	default:
+		return occurrencePlan{}, validationError("scope must be occurrence or following")
+	}
+	return plan, nil
+}
+
+// occurrenceIndex возвращает номер повторения date задачи, 0 — текущая дата
+// задачи. Повторения ищутся среди ближайших util.MaxOccurrences; даты из
+// exdates и будущие даты задачи с якорем AnchorCompletion не подходят.
+func occurrenceIndex(task domain.Task, exdates []string, date string) (int, error) {
+	switch {
+	case slices.Contains(exdates, date):
+		return 0, errNotOccurrence
+	case date == task.Date:
+		return 0, nil
+	case date < task.Date || task.Anchor == domain.AnchorCompletion:
+		return 0, errNotOccurrence
+	}
+
+	limit := util.MaxOccurrences
+	if seriesCount(task.Repeat) > 0 {
+		limit = min(limit, task.Remaining-1)
+	}
+	start, _ := time.Parse(util.DateFormat, task.Date)
+	dates, err := util.Occurrences(context.Background(), start, task.Date, task.Repeat, limit, date)
+	if err != nil {
+		return 0, err
+	}
+	if len(dates) == 0 || dates[len(dates)-1] != date {
+		return 0, errNotOccurrence
+	}
+	return len(dates), nil
+}
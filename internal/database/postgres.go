@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+
+	_ "github.com/lib/pq"
+)
+
+func openPostgresDB(url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть БД: %v", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection failed: %v", err)
+	}
+
+	return db, nil
+}
+
+// OpenPostgres подключается к PostgreSQL и применяет недостающие миграции.
+func OpenPostgres(url string) (Store, error) {
+	db, err := openPostgresDB(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := (migrator{db: db, dialect: postgresDialect}).migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	log.Println("Подключение к PostgreSQL установлено")
+	return &sqlStore{db: db, dialect: postgresDialect}, nil
+}
+
+func printPendingPostgres(url string, w io.Writer) error {
+	db, err := openPostgresDB(url)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return migrator{db: db, dialect: postgresDialect}.printPending(w)
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"modernc.org/sqlite"
+)
+
+// Встроенная lower в SQLite меняет регистр только латиницы. Условия поиска
+// (query.Text) сравнивают строки в нижнем регистре, поэтому lower заменяется
+// на strings.ToLower — так же, как хранилище в памяти и PostgreSQL.
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("lower", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch v := args[0].(type) {
+		case string:
+			return strings.ToLower(v), nil
+		case []byte:
+			return strings.ToLower(string(v)), nil
+		}
+		return args[0], nil
+	})
+}
+
+func openSQLiteDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть БД: %v", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database connection failed: %v", err)
+	}
+
+	return db, nil
+}
+
+// OpenSQLite открывает файл БД и применяет недостающие миграции.
+func OpenSQLite(path string) (Store, error) {
+	if os.Getenv("GO_TEST") == "1" {
+		os.Remove(path)
+	}
+
+	db, err := openSQLiteDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := migrator{db: db, dialect: sqliteDialect}
+	if err := m.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// индекса нет, если миграция 0012_task_fts пропущена из-за сборки без FTS5
+	fts, err := m.tableExists("scheduler_fts")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to check full-text index: %w", err)
+	}
+	if !fts {
+		slog.Warn("SQLite собран без FTS5, полнотекстовый поиск заменяется поиском подстроки")
+	}
+
+	log.Println("База данных успешно инициализирована")
+	return &sqlStore{db: db, dialect: sqliteDialect, fts: fts}, nil
+}
+
+// printPendingSQLite открывает базу только на чтение: пробный запуск не должен
+// ни создавать файл, ни изменять его.
+func printPendingSQLite(path string, w io.Writer) error {
+	m := migrator{dialect: sqliteDialect}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(w, "База данных %s не найдена\n", path)
+		migrations, err := m.load()
+		if err != nil {
+			return err
+		}
+		printMigrations(w, migrations)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("не удалось открыть БД: %v", err)
+	}
+
+	db, err := openSQLiteDB("file:" + path + "?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m.db = db
+	return m.printPending(w)
+}
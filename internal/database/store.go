@@ -0,0 +1,311 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// TaskStore — хранилище задач. Все операции ограничены задачами владельца
+// ownerID; отсутствующая или чужая задача возвращает ErrTaskNotFound,
+// некорректные данные — ошибку вида ErrValidation.
+//
+// Изменяющие операции принимают ожидаемую версию задачи: если она не 0 и не
+// совпадает с текущей, возвращается ErrVersionMismatch. Каждое изменение
+// увеличивает версию.
+type TaskStore interface {
+	Add(ownerID int64, task domain.Task) (int64, error)
+	Get(ownerID, id int64) (domain.Task, error)
+	// Update полностью заменяет задачу task.ID, пустые поля очищаются.
+	Update(ownerID int64, task domain.Task, version int64) (domain.Task, error)
+	// Patch меняет только переданные в patch поля.
+	Patch(ownerID, id int64, patch domain.TaskPatch, version int64) (domain.Task, error)
+	Delete(ownerID, id, version int64) error
+	List(ownerID int64, q TaskQuery) (TaskPage, error)
+	// Complete удаляет разовую задачу или переносит повторяющуюся на следующую
+	// дату с учётом её политики CatchUp (см. nextAfterDone) и записывает
+	// выполнение в историю.
+	Complete(ownerID, id int64, now time.Time, version int64) error
+	// Completions возвращает выполнения задач по q, последние первыми.
+	Completions(ownerID int64, q CompletionQuery) ([]domain.Completion, error)
+	// Reopen восстанавливает выполненную разовую задачу id по последней записи
+	// о выполнении и удаляет эту запись.
+	Reopen(ownerID, id int64) (domain.Task, error)
+	// Skip переносит повторяющуюся задачу на следующее повторение без
+	// выполнения; событие записывается в историю.
+	Skip(ownerID, id int64, now time.Time, version int64) (domain.Task, error)
+	// Snooze откладывает задачу по s; событие записывается в историю.
+	Snooze(ownerID, id int64, s Snooze, now time.Time, version int64) (domain.Task, error)
+	// History возвращает события задачи id в порядке записи.
+	History(ownerID, id int64) ([]domain.TaskEvent, error)
+	// PatchOccurrence меняет повторение date задачи id (пусто — текущее):
+	// ScopeOccurrence — только его, оно заменяется разовой задачей;
+	// ScopeFollowing — его и следующие, с date начинается новая серия.
+	// Возвращает разовую задачу или новую серию.
+	PatchOccurrence(ownerID, id int64, date, scope string, patch domain.TaskPatch, now time.Time, version int64) (domain.Task, error)
+	// DeleteOccurrence отменяет повторение date задачи id (ScopeOccurrence)
+	// или его и все следующие (ScopeFollowing).
+	DeleteOccurrence(ownerID, id int64, date, scope string, now time.Time, version int64) error
+	// Exceptions возвращает отменённые и заменённые повторения задачи id по дате.
+	Exceptions(ownerID, id int64) ([]domain.TaskException, error)
+}
+
+type UserStore interface {
+	CreateUser(login, passwordHash string) (int64, error)
+	UserByLogin(login string) (domain.User, error)
+	UserByID(id int64) (domain.User, error)
+	// AuthSecret возвращает ключ подписи токенов, при первом запуске создаёт его.
+	AuthSecret() ([]byte, error)
+}
+
+type Store interface {
+	TaskStore
+	UserStore
+	Close() error
+}
+
+// Open выбирает хранилище по url: postgres://… — PostgreSQL,
+// memory: — память процесса, пустое значение — файл SQLite path.
+func Open(url, path string) (Store, error) {
+	backend, err := backendOf(url)
+	if err != nil {
+		return nil, err
+	}
+
+	switch backend {
+	case "memory":
+		return NewMemory(), nil
+	case "postgres":
+		return OpenPostgres(url)
+	default:
+		return OpenSQLite(path)
+	}
+}
+
+// PrintPendingMigrations выводит непримененные миграции выбранного хранилища.
+func PrintPendingMigrations(url, path string, w io.Writer) error {
+	backend, err := backendOf(url)
+	if err != nil {
+		return err
+	}
+
+	switch backend {
+	case "memory":
+		_, err := fmt.Fprintln(w, "Хранилище в памяти не использует миграции")
+		return err
+	case "postgres":
+		return printPendingPostgres(url, w)
+	default:
+		return printPendingSQLite(path, w)
+	}
+}
+
+func backendOf(url string) (string, error) {
+	switch {
+	case url == "":
+		return "sqlite", nil
+	case url == "memory:" || strings.HasPrefix(url, "memory://"):
+		return "memory", nil
+	case strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://"):
+		return "postgres", nil
+	default:
+		return "", fmt.Errorf("unsupported TODO_DB_URL: %s", url)
+	}
+}
+
+// prepare нормализует и проверяет задачу перед записью.
+func prepare(task domain.Task) (domain.Task, error) {
+	task.Normalize()
+	if errs := task.Validate(); len(errs) > 0 {
+		return domain.Task{}, fieldsError(errs)
+	}
+	return task, nil
+}
+
+// applyPatch применяет изменения к current и проверяет результат.
+func applyPatch(current domain.Task, patch domain.TaskPatch) (domain.Task, error) {
+	if patch.Empty() {
+		return domain.Task{}, validationError("nothing to update")
+	}
+	return prepare(patch.Apply(current))
+}
+
+// createdFormat — формат времени создания задачи; фиксированная ширина
+// позволяет сортировать его как строку.
+const createdFormat = "2006-01-02T15:04:05.000000Z"
+
+func createdNow() string {
+	return time.Now().UTC().Format(createdFormat)
+}
+
+// checkVersion сверяет версию задачи с ожидаемой; 0 — без проверки.
+func checkVersion(task domain.Task, version int64) error {
+	if version != 0 && task.Version != version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// seriesCount возвращает число повторений из модификатора count: правила.
+func seriesCount(repeat string) int {
+	rule, err := util.ParseRule(repeat)
+	if err != nil {
+		return 0
+	}
+	return rule.Limits.Count
+}
+
+// maxMissed — сколько задач на пропущенные повторения создаётся за одно
+// выполнение с политикой CatchUpSpawn; более поздние пропуски не создаются.
+const maxMissed = 100
+
+// doneResult — задача после выполнения.
+type doneResult struct {
+	// next — задача с новой датой и уменьшенным Remaining.
+	next domain.Task
+	// keep — false, если серия закончилась и задачу нужно удалить.
+	keep bool
+	// missed — разовые задачи на пропущенные повторения (CatchUpSpawn).
+	missed []domain.Task
+}
+
+// nextAfterDone переносит задачу на следующую дату после выполнения в день now.
+// Даты из exdates (отменённые и заменённые повторения) пропускаются, но
+// расходуют count:. Для якоря AnchorCompletion дата отсчитывается от now. Политика CatchUp
+// определяет следующую дату просроченной задачи: CatchUpSkip — первая позже
+// now, CatchUpOne — первая позже даты задачи, CatchUpSpawn — первая позже
+// now, а каждое пропущенное повторение до now включительно становится
+// отдельной разовой задачей и расходует count:.
+func nextAfterDone(task domain.Task, exdates []string, now time.Time) (doneResult, error) {
+	if task.Repeat == "" {
+		return doneResult{}, nil
+	}
+	if fe := domain.ValidateRepeat(task.Date, task.Repeat); fe != nil {
+		return doneResult{}, fieldsError(domain.FieldErrors{*fe})
+	}
+
+	counted := seriesCount(task.Repeat) > 0
+	if counted {
+		if task.Remaining <= 1 {
+			return doneResult{}, nil
+		}
+		task.Remaining--
+	}
+
+	var res doneResult
+	from, after := task.Date, now
+	switch {
+	case task.Anchor == domain.AnchorCompletion:
+		from = now.Format(util.DateFormat)
+	case task.CatchUp == domain.CatchUpOne:
+		after, _ = time.Parse(util.DateFormat, task.Date)
+	case task.CatchUp == domain.CatchUpSpawn:
+		limit := maxMissed
+		if counted {
+			limit = min(limit, task.Remaining)
+		}
+		start, _ := time.Parse(util.DateFormat, task.Date)
+		dates, err := util.Occurrences(context.Background(), start, task.Date, task.Repeat, limit, now.Format(util.DateFormat))
+		if err != nil {
+			return doneResult{}, err
+		}
+		for _, date := range dates {
+			if !slices.Contains(exdates, date) {
+				res.missed = append(res.missed, domain.Task{Date: date, Title: task.Title, Comment: task.Comment})
+			}
+		}
+		if counted {
+			// все оставшиеся повторения стали отдельными задачами
+			if task.Remaining -= len(dates); task.Remaining == 0 {
+				return res, nil
+			}
+		}
+	}
+
+	next, err := util.NextDate(after, from, task.Repeat)
+	for err == nil && slices.Contains(exdates, next) {
+		if counted {
+			if task.Remaining <= 1 {
+				return res, nil
+			}
+			task.Remaining--
+		}
+		after, _ = time.Parse(util.DateFormat, next)
+		next, err = util.NextDate(after, from, task.Repeat)
+	}
+	if errors.Is(err, util.ErrSeriesEnded) {
+		return res, nil
+	}
+	if err != nil {
+		return doneResult{}, err
+	}
+	task.Date = next
+	res.next, res.keep = task, true
+	return res, nil
+}
+
+// nextAfterSkip переносит задачу на следующее повторение так же, как
+// выполнение, но без задач на пропущенные повторения. Разовую задачу
+// пропустить нельзя; если серия закончилась, возвращается ErrNoNextOccurrence.
+func nextAfterSkip(task domain.Task, exdates []string, now time.Time) (domain.Task, error) {
+	if task.Repeat == "" {
+		return domain.Task{}, validationError("only recurring tasks can be skipped")
+	}
+
+	policy := task.CatchUp
+	if policy == domain.CatchUpSpawn {
+		task.CatchUp = ""
+	}
+	done, err := nextAfterDone(task, exdates, now)
+	if err != nil {
+		return domain.Task{}, err
+	}
+	if !done.keep {
+		return domain.Task{}, ErrNoNextOccurrence
+	}
+	done.next.CatchUp = policy
+	return done.next, nil
+}
+
+// MaxSnoozeDays — на сколько дней можно отложить задачу за раз.
+const MaxSnoozeDays = 366
+
+// Snooze — насколько отложить задачу: до даты Until или на Days дней.
+type Snooze struct {
+	Until string
+	Days  int
+}
+
+// snoozed возвращает задачу, отложенную по s. Days отсчитываются от даты
+// задачи, а у просроченной — от now. Until должна быть позже даты задачи
+// и не раньше now.
+func snoozed(task domain.Task, s Snooze, now time.Time) (domain.Task, error) {
+	today := now.Format(util.DateFormat)
+	switch {
+	case s.Until != "" && s.Days != 0:
+		return domain.Task{}, validationError("either until or days must be set, not both")
+	case s.Until != "":
+		if fe := domain.ValidateDate("until", s.Until); fe != nil {
+			return domain.Task{}, fieldsError(domain.FieldErrors{*fe})
+		}
+		if s.Until <= task.Date || s.Until < today {
+			return domain.Task{}, fieldsError(domain.FieldErrors{{
+				Field: "until", Code: domain.CodeInvalidDate, Message: "until must be after the task date and not in the past",
+			}})
+		}
+		task.Date = s.Until
+	case s.Days >= 1 && s.Days <= MaxSnoozeDays:
+		from, _ := time.Parse(util.DateFormat, max(task.Date, today))
+		task.Date = from.AddDate(0, 0, s.Days).Format(util.DateFormat)
+	default:
+		return domain.Task{}, validationError("days must be from 1 to %d", MaxSnoozeDays)
+	}
+	return prepare(task)
+}
@@ -0,0 +1,848 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Общий набор проверок, который должен проходить каждый бэкенд.
+func testStore(t *testing.T, open func(t *testing.T) Store) {
+	t.Run("AddValidates", func(t *testing.T) {
+		s := open(t)
+		for _, task := range []domain.Task{
+			{Date: "20240126"},
+			{Date: "20240192", Title: "Задача"},
+			{Date: "26.01.2024", Title: "Задача"},
+			{Date: "20240126", Title: "Задача", Repeat: "ooops"},
+		} {
+			_, err := s.Add(1, task)
+			assert.ErrorIs(t, err, ErrValidation, "%+v", task)
+		}
+	})
+
+	t.Run("AddGet", func(t *testing.T) {
+		s := open(t)
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Задача", Comment: "Комментарий", Repeat: "d 5"})
+		require.NoError(t, err)
+		assert.NotZero(t, id)
+
+		task, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.NotEmpty(t, task.CreatedAt)
+		assert.Equal(t, domain.Task{ID: id, Date: "20240126", Title: "Задача", Comment: "Комментарий", Repeat: "d 5", Version: 1, CreatedAt: task.CreatedAt}, task)
+
+		_, err = s.Get(1, id+100)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = s.Get(2, id)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		s := open(t)
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Задача", Comment: "Комментарий", Repeat: "d 5"})
+		require.NoError(t, err)
+
+		task, err := s.Update(1, domain.Task{ID: id, Date: "20240127", Title: " Новая "}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Task{ID: id, Date: "20240127", Title: "Новая", Version: 2, CreatedAt: task.CreatedAt}, task)
+
+		stored, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, task, stored)
+
+		for _, v := range []domain.Task{
+			{ID: id, Title: "Без даты"},
+			{ID: id, Date: "20240192", Title: "Задача"},
+			{ID: id, Date: "20240126", Title: "   "},
+			{ID: id, Date: "20240126", Title: strings.Repeat("x", 101)},
+			{ID: id, Date: "20240126", Title: "Задача", Repeat: "ooops"},
+		} {
+			_, err := s.Update(1, v, 0)
+			assert.ErrorIs(t, err, ErrValidation, "%+v", v)
+		}
+
+		_, err = s.Update(2, domain.Task{ID: id, Date: "20240126", Title: "Чужая"}, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+		stored, err = s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Новая", stored.Title)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		s := open(t)
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Задача", Comment: "Комментарий", Repeat: "d 5"})
+		require.NoError(t, err)
+		str := func(v string) *string { return &v }
+
+		task, err := s.Patch(1, id, domain.TaskPatch{Title: str("Новая")}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Task{ID: id, Date: "20240126", Title: "Новая", Comment: "Комментарий", Repeat: "d 5", Version: 2, CreatedAt: task.CreatedAt}, task)
+
+		task, err = s.Patch(1, id, domain.TaskPatch{Comment: str(""), Repeat: str("")}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Task{ID: id, Date: "20240126", Title: "Новая", Version: 3, CreatedAt: task.CreatedAt}, task)
+
+		stored, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, task, stored)
+
+		for _, p := range []domain.TaskPatch{
+			{},
+			{Title: str("")},
+			{Date: str("")},
+			{Repeat: str("ooops")},
+		} {
+			_, err := s.Patch(1, id, p, 0)
+			assert.ErrorIs(t, err, ErrValidation, "%+v", p)
+		}
+		_, err = s.Patch(2, id, domain.TaskPatch{Title: str("Чужая")}, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := open(t)
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Задача"})
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, s.Delete(2, id, 0), ErrNotFound)
+		assert.NoError(t, s.Delete(1, id, 0))
+		assert.ErrorIs(t, s.Delete(1, id, 0), ErrNotFound)
+		_, err = s.Get(1, id)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		s := open(t)
+		for _, task := range []domain.Task{
+			{Date: "20240128", Title: "Позвонить в УК", Comment: "горячая вода"},
+			{Date: "20240126", Title: "Бассейн"},
+			{Date: "20240127", Title: "Фильм", Comment: "с попкорном"},
+			{Date: "20240126", Title: "Магазин"},
+		} {
+			_, err := s.Add(1, task)
+			require.NoError(t, err)
+		}
+		_, err := s.Add(2, domain.Task{Date: "20240101", Title: "Чужая задача УК"})
+		require.NoError(t, err)
+
+		titles := func(search string, limit int) []string {
+			page, err := s.List(1, TaskQuery{Search: search, Limit: limit})
+			require.NoError(t, err)
+			var out []string
+			for _, task := range page.Tasks {
+				out = append(out, task.Title)
+			}
+			return out
+		}
+
+		assert.Equal(t, []string{"Бассейн", "Магазин", "Фильм", "Позвонить в УК"}, titles("", 50))
+		assert.Equal(t, []string{"Бассейн", "Магазин"}, titles("", 2))
+		assert.Equal(t, []string{"Позвонить в УК"}, titles("УК", 50))
+		assert.Equal(t, []string{"Фильм"}, titles("попкорн", 50))
+		assert.Equal(t, []string{"Бассейн", "Магазин"}, titles("26.01.2024", 50))
+		assert.Empty(t, titles("ничего", 50))
+
+		// подстрока ищется без учёта регистра, в том числе в кириллице
+		_, err = s.Add(1, domain.Task{Date: "20240129", Title: "Оплатить ЖКХ", Comment: "через WebMoney"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Позвонить в УК"}, titles("позвонить в ук", 50))
+		assert.Equal(t, []string{"Позвонить в УК"}, titles("ГОРЯЧАЯ", 50))
+		assert.Equal(t, []string{"Оплатить ЖКХ"}, titles("жкх", 50))
+		assert.Equal(t, []string{"Оплатить ЖКХ"}, titles("WEBmoney", 50))
+
+		page, err := s.List(3, TaskQuery{Limit: 50})
+		require.NoError(t, err)
+		assert.NotNil(t, page.Tasks)
+		assert.Empty(t, page.Tasks)
+		assert.Zero(t, page.Total)
+	})
+
+	t.Run("ListPages", func(t *testing.T) {
+		s := open(t)
+		for _, task := range []domain.Task{
+			{Date: "20240128", Title: "Вода", Repeat: "d 7"},
+			{Date: "20240126", Title: "Бассейн", Repeat: "w 1,3"},
+			{Date: "20240127", Title: "Фильм"},
+			{Date: "20240126", Title: "Магазин"},
+			{Date: "20240301", Title: "Аптека", Repeat: "d 30"},
+		} {
+			_, err := s.Add(1, task)
+			require.NoError(t, err)
+		}
+		yes, no := true, false
+
+		// all проходит по всем страницам и проверяет, что курсор не теряет и не повторяет задачи.
+		all := func(q TaskQuery) []string {
+			var out []string
+			for {
+				page, err := s.List(1, q)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Tasks), q.Limit)
+				for _, task := range page.Tasks {
+					out = append(out, task.Title)
+				}
+				if page.NextCursor == "" {
+					return out
+				}
+				q.Cursor = page.NextCursor
+			}
+		}
+
+		assert.Equal(t, []string{"Бассейн", "Магазин", "Фильм", "Вода", "Аптека"}, all(TaskQuery{Limit: 2}))
+		assert.Equal(t, []string{"Аптека", "Вода", "Фильм", "Магазин", "Бассейн"}, all(TaskQuery{Limit: 2, Desc: true}))
+		assert.Equal(t, []string{"Аптека", "Бассейн", "Вода", "Магазин", "Фильм"}, all(TaskQuery{Limit: 3, Sort: SortTitle}))
+		assert.Equal(t, []string{"Аптека", "Магазин", "Фильм", "Бассейн", "Вода"}, all(TaskQuery{Limit: 1, Sort: SortID, Desc: true}))
+		assert.Equal(t, []string{"Вода", "Бассейн", "Фильм", "Магазин", "Аптека"}, all(TaskQuery{Limit: 4, Sort: SortCreated}))
+		assert.Equal(t, []string{"Фильм", "Вода"}, all(TaskQuery{Limit: 1, DateFrom: "20240127", DateTo: "20240128"}))
+		assert.Equal(t, []string{"Бассейн", "Вода", "Аптека"}, all(TaskQuery{Limit: 50, HasRepeat: &yes}))
+		assert.Equal(t, []string{"Магазин", "Фильм"}, all(TaskQuery{Limit: 50, HasRepeat: &no}))
+		assert.Equal(t, []string{"Вода", "Аптека"}, all(TaskQuery{Limit: 50, RepeatType: "d"}))
+
+		now := time.Date(2024, 1, 27, 10, 0, 0, 0, time.UTC)
+		assert.Equal(t, []string{"Бассейн", "Магазин"}, all(TaskQuery{Limit: 50, Search: "overdue", Now: now}))
+		assert.Equal(t, []string{"Бассейн", "Магазин", "Фильм", "Вода"}, all(TaskQuery{Limit: 3, Search: "this week", Now: now}))
+		assert.Equal(t, []string{"Фильм", "Вода", "Аптека"}, all(TaskQuery{Limit: 50, Search: "2024-01-27..2024-03-01"}))
+		// дата из search сужается фильтрами date_from/date_to
+		assert.Equal(t, []string{"Вода"}, all(TaskQuery{Limit: 50, Search: "this week", Now: now, DateFrom: "20240128"}))
+		_, err := s.List(1, TaskQuery{Limit: 50, Search: "01.03.2024-27.01.2024"})
+		assert.ErrorIs(t, err, ErrValidation)
+
+		filter, err := query.Parse(`date:>=27.01.2024 -repeat:w -"Апт"`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Фильм", "Вода"}, all(TaskQuery{Limit: 1, Filter: filter}))
+		filter, err = query.Parse(`title:"Бас" OR`)
+		require.NoError(t, err)
+		assert.Empty(t, all(TaskQuery{Limit: 50, Filter: filter}))
+		// спецсимволы LIKE ищутся буквально
+		filter, err = query.Parse("_")
+		require.NoError(t, err)
+		assert.Empty(t, all(TaskQuery{Limit: 50, Filter: filter}))
+
+		page, err := s.List(1, TaskQuery{Limit: 2, HasRepeat: &yes})
+		require.NoError(t, err)
+		assert.Equal(t, 3, page.Total)
+		assert.NotEmpty(t, page.NextCursor)
+
+		for _, q := range []TaskQuery{
+			{Limit: 0},
+			{Limit: 10, Sort: "owner_id"},
+			{Limit: 10, DateFrom: "2024-01-01"},
+			{Limit: 10, RepeatType: "x"},
+			{Limit: 10, Cursor: "не курсор"},
+			{Limit: 10, Cursor: page.NextCursor, Sort: SortTitle},
+		} {
+			_, err := s.List(1, q)
+			assert.ErrorIs(t, err, ErrValidation, "%+v", q)
+		}
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+		once, err := s.Add(1, domain.Task{Date: "20240126", Title: "Разовая"})
+		require.NoError(t, err)
+		assert.ErrorIs(t, s.Complete(2, once, now, 0), ErrNotFound)
+		require.NoError(t, s.Complete(1, once, now, 0))
+		_, err = s.Get(1, once)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		repeated, err := s.Add(1, domain.Task{Date: "20240126", Title: "Повтор", Repeat: "d 3"})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(1, repeated, now, 0))
+		task, err := s.Get(1, repeated)
+		require.NoError(t, err)
+		assert.Equal(t, "20240129", task.Date)
+	})
+
+	t.Run("Series", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+		// count: задача удаляется после последнего повторения
+		counted, err := s.Add(1, domain.Task{Date: "20240126", Title: "Трижды", Repeat: "d 1 count:3"})
+		require.NoError(t, err)
+		task, err := s.Get(1, counted)
+		require.NoError(t, err)
+		assert.Equal(t, 3, task.Remaining)
+
+		require.NoError(t, s.Complete(1, counted, now, 0))
+		task, err = s.Get(1, counted)
+		require.NoError(t, err)
+		assert.Equal(t, "20240127", task.Date)
+		assert.Equal(t, 2, task.Remaining)
+
+		// правка без смены правила счётчик не сбрасывает, смена правила — сбрасывает
+		task, err = s.Update(1, domain.Task{ID: counted, Date: task.Date, Title: "Трижды!", Repeat: task.Repeat}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, task.Remaining)
+		repeat := "d 2 count:5"
+		task, err = s.Patch(1, counted, domain.TaskPatch{Repeat: &repeat}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 5, task.Remaining)
+		repeat = "d 1 count:2"
+		task, err = s.Patch(1, counted, domain.TaskPatch{Repeat: &repeat}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, task.Remaining)
+
+		require.NoError(t, s.Complete(1, counted, now, 0))
+		require.NoError(t, s.Complete(1, counted, now, 0))
+		_, err = s.Get(1, counted)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// until: следующая дата позже until — серия закончилась
+		until, err := s.Add(1, domain.Task{Date: "20240126", Title: "До 30-го", Repeat: "d 3 until:20240130"})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(1, until, now, 0))
+		task, err = s.Get(1, until)
+		require.NoError(t, err)
+		assert.Equal(t, "20240129", task.Date)
+		assert.Zero(t, task.Remaining)
+		require.NoError(t, s.Complete(1, until, now, 0))
+		_, err = s.Get(1, until)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("CompletionAnchor", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+		// от дня выполнения, а не от даты задачи
+		id, err := s.Add(1, domain.Task{Date: "20240120", Title: "Полить цветы", Repeat: "d 5", Anchor: domain.AnchorCompletion})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(1, id, now, 0))
+		task, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "20240131", task.Date)
+		assert.Equal(t, domain.AnchorCompletion, task.Anchor)
+
+		// якорь по расписанию хранится пустой строкой
+		anchor := domain.AnchorSchedule
+		task, err = s.Patch(1, id, domain.TaskPatch{Anchor: &anchor}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "", task.Anchor)
+		require.NoError(t, s.Complete(1, id, now, 0))
+		task, err = s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "20240205", task.Date)
+
+		// без правила повторения якорь completion не имеет смысла
+		_, err = s.Add(1, domain.Task{Date: "20240120", Title: "Разовая", Anchor: domain.AnchorCompletion})
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+
+	t.Run("CatchUp", func(t *testing.T) {
+		s := open(t)
+		// задача по понедельникам просрочена на три повторения: 8, 15 и 22 января
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+		dates := func(owner int64) []string {
+			page, err := s.List(owner, TaskQuery{Limit: 50})
+			require.NoError(t, err)
+			var dates []string
+			for _, task := range page.Tasks {
+				dates = append(dates, task.Date)
+			}
+			return dates
+		}
+
+		for _, tc := range []struct {
+			catchUp string
+			want    string
+		}{
+			{"", "20240129"},
+			{domain.CatchUpSkip, "20240129"},
+			{domain.CatchUpOne, "20240108"},
+		} {
+			id, err := s.Add(1, domain.Task{Date: "20240101", Title: "Отчёт", Repeat: "w 1", CatchUp: tc.catchUp})
+			require.NoError(t, err)
+			require.NoError(t, s.Complete(1, id, now, 0))
+			task, err := s.Get(1, id)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, task.Date, tc.catchUp)
+		}
+
+		id, err := s.Add(2, domain.Task{Date: "20240101", Title: "Отчёт", Comment: "еженедельный", Repeat: "w 1", CatchUp: domain.CatchUpSpawn})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(2, id, now, 0))
+		assert.Equal(t, []string{"20240108", "20240115", "20240122", "20240129"}, dates(2))
+		page, err := s.List(2, TaskQuery{Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "еженедельный", page.Tasks[0].Comment)
+		assert.Empty(t, page.Tasks[0].Repeat)
+		task, err := s.Get(2, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.CatchUpSpawn, task.CatchUp)
+
+		// пропущенные повторения расходуют count:, серия может закончиться
+		id, err = s.Add(3, domain.Task{Date: "20240101", Title: "Отчёт", Repeat: "w 1 count:3", CatchUp: domain.CatchUpSpawn})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(3, id, now, 0))
+		assert.Equal(t, []string{"20240108", "20240115"}, dates(3))
+		_, err = s.Get(3, id)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = s.Add(1, domain.Task{Date: "20240101", Title: "Разовая", CatchUp: domain.CatchUpOne})
+		assert.ErrorIs(t, err, ErrValidation)
+	})
+
+	t.Run("SkipSnooze", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+		id, err := s.Add(1, domain.Task{Date: "20240129", Title: "Планёрка", Repeat: "d 7 count:2"})
+		require.NoError(t, err)
+		task, err := s.Skip(1, id, now, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "20240205", task.Date)
+		assert.Equal(t, 1, task.Remaining)
+		assert.EqualValues(t, 2, task.Version)
+		// пропуск расходует count:, после последнего повторения пропускать нечего
+		_, err = s.Skip(1, id, now, 0)
+		assert.ErrorIs(t, err, ErrConflict)
+
+		// пропуск не создаёт задач на пропущенные повторения
+		spawn, err := s.Add(2, domain.Task{Date: "20240101", Title: "Отчёт", Repeat: "w 1", CatchUp: domain.CatchUpSpawn})
+		require.NoError(t, err)
+		task, err = s.Skip(2, spawn, now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "20240129", task.Date)
+		assert.Equal(t, domain.CatchUpSpawn, task.CatchUp)
+		page, err := s.List(2, TaskQuery{Limit: 50})
+		require.NoError(t, err)
+		assert.Len(t, page.Tasks, 1)
+
+		once, err := s.Add(1, domain.Task{Date: "20240120", Title: "Позвонить"})
+		require.NoError(t, err)
+		_, err = s.Skip(1, once, now, 0)
+		assert.ErrorIs(t, err, ErrValidation)
+
+		// просроченная задача откладывается от сегодняшнего дня
+		task, err = s.Snooze(1, once, Snooze{Days: 3}, now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "20240129", task.Date)
+		task, err = s.Snooze(1, once, Snooze{Days: 3}, now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "20240201", task.Date)
+		task, err = s.Snooze(1, once, Snooze{Until: "20240210"}, now, task.Version)
+		require.NoError(t, err)
+		assert.Equal(t, "20240210", task.Date)
+
+		for _, snooze := range []Snooze{{}, {Days: MaxSnoozeDays + 1}, {Until: "20240205"}, {Until: "10.03.2024"}, {Until: "20240301", Days: 1}} {
+			_, err = s.Snooze(1, once, snooze, now, 0)
+			assert.ErrorIs(t, err, ErrValidation, "%+v", snooze)
+		}
+		_, err = s.Snooze(1, once, Snooze{Days: 1}, now, 1)
+		assert.ErrorIs(t, err, ErrPrecondition)
+		_, err = s.Snooze(2, once, Snooze{Days: 1}, now, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		events, err := s.History(1, once)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, domain.TaskEvent{TaskID: once, Action: domain.EventSnooze, From: "20240120", To: "20240129", At: events[0].At}, events[0])
+		assert.Equal(t, "20240210", events[2].To)
+		events, err = s.History(1, id)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, domain.EventSkip, events[0].Action)
+		events, err = s.History(2, id)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("Occurrences", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+		title := func(title string) domain.TaskPatch { return domain.TaskPatch{Title: &title} }
+
+		// повторения 29.01, 05.02, 12.02, 19.02, 26.02, …
+		id, err := s.Add(1, domain.Task{Date: "20240129", Title: "Планёрка", Comment: "переговорка 3", Repeat: "d 7"})
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteOccurrence(1, id, "20240205", domain.ScopeOccurrence, now, 0))
+		require.NoError(t, s.Complete(1, id, now, 0))
+		task, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "20240212", task.Date)
+
+		one, err := s.PatchOccurrence(1, id, "20240219", domain.ScopeOccurrence, title("Планёрка онлайн"), now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Task{ID: one.ID, Date: "20240219", Title: "Планёрка онлайн", Comment: "переговорка 3", Version: 1, CreatedAt: one.CreatedAt}, one)
+
+		// замена текущего повторения переносит серию на следующее
+		task, err = s.Get(1, id)
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, task.Version)
+		date := "20240213"
+		moved, err := s.PatchOccurrence(1, id, "", domain.ScopeOccurrence, domain.TaskPatch{Date: &date}, now, task.Version)
+		require.NoError(t, err)
+		assert.Equal(t, "20240213", moved.Date)
+		task, err = s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "20240226", task.Date)
+		assert.Equal(t, "Планёрка", task.Title)
+
+		exceptions, err := s.Exceptions(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, []domain.TaskException{
+			{TaskID: id, Date: "20240205"},
+			{TaskID: id, Date: "20240212", OverrideID: moved.ID},
+			{TaskID: id, Date: "20240219", OverrideID: one.ID},
+		}, exceptions)
+
+		repeat := "d 1"
+		for _, tc := range []struct {
+			date, scope string
+			patch       domain.TaskPatch
+		}{
+			{"20240227", domain.ScopeOccurrence, title("Нет такого")},
+			{"20240219", domain.ScopeOccurrence, title("Уже заменено")},
+			{"20240304", domain.ScopeOccurrence, domain.TaskPatch{Repeat: &repeat}},
+			{"20240304", "all", title("Неизвестно")},
+			{"04.03.2024", domain.ScopeOccurrence, title("Формат")},
+		} {
+			_, err = s.PatchOccurrence(1, id, tc.date, tc.scope, tc.patch, now, 0)
+			assert.ErrorIs(t, err, ErrValidation, "%+v", tc)
+		}
+		_, err = s.PatchOccurrence(1, one.ID, "", domain.ScopeOccurrence, title("Разовая"), now, 0)
+		assert.ErrorIs(t, err, ErrValidation)
+		_, err = s.PatchOccurrence(1, id, "", domain.ScopeOccurrence, title("Старая версия"), now, 1)
+		assert.ErrorIs(t, err, ErrPrecondition)
+
+		// это и следующие: серия делится, исключения после даты переходят к новой
+		require.NoError(t, s.DeleteOccurrence(1, id, "20240318", domain.ScopeOccurrence, now, 0))
+		series, err := s.PatchOccurrence(1, id, "20240311", domain.ScopeFollowing, title("Новая планёрка"), now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "20240311", series.Date)
+		assert.Equal(t, "Новая планёрка", series.Title)
+		assert.Equal(t, "d 7", series.Repeat)
+		task, err = s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "d 7 until:20240310", task.Repeat)
+		exceptions, err = s.Exceptions(1, series.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []domain.TaskException{{TaskID: series.ID, Date: "20240318"}}, exceptions)
+		exceptions, err = s.Exceptions(1, id)
+		require.NoError(t, err)
+		assert.Len(t, exceptions, 3)
+
+		// count: делится между сериями
+		id, err = s.Add(2, domain.Task{Date: "20240129", Title: "Курс", Repeat: "d 7 count:5"})
+		require.NoError(t, err)
+		_, err = s.PatchOccurrence(2, id, "20240304", domain.ScopeFollowing, title("Нет такого"), now, 0)
+		assert.ErrorIs(t, err, ErrValidation)
+		series, err = s.PatchOccurrence(2, id, "20240212", domain.ScopeFollowing, title("Курс, часть 2"), now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "d 7 count:3", series.Repeat)
+		assert.Equal(t, 3, series.Remaining)
+		task, err = s.Get(2, id)
+		require.NoError(t, err)
+		assert.Equal(t, "d 7 until:20240211", task.Repeat)
+		assert.Zero(t, task.Remaining)
+
+		require.NoError(t, s.DeleteOccurrence(2, series.ID, "20240219", domain.ScopeFollowing, now, 0))
+		task, err = s.Get(2, series.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "d 7 until:20240218", task.Repeat)
+		require.NoError(t, s.DeleteOccurrence(2, series.ID, "", domain.ScopeFollowing, now, 0))
+		_, err = s.Get(2, series.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("DeleteClearsExceptions", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 10, 0, 0, 0, time.UTC)
+
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Планёрка", Repeat: "d 7"})
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteOccurrence(1, id, "20240202", domain.ScopeOccurrence, now, 0))
+		title := "Перенесённая"
+		_, err = s.PatchOccurrence(1, id, "20240209", domain.ScopeOccurrence, domain.TaskPatch{Title: &title}, now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, exceptionRows(t, s, id))
+
+		require.NoError(t, s.Delete(1, id, 0))
+		assert.Zero(t, exceptionRows(t, s, id))
+
+		// серия, закончившаяся выполнением, тоже не оставляет исключений
+		id, err = s.Add(1, domain.Task{Date: "20240126", Title: "Курс", Repeat: "d 7 count:3"})
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteOccurrence(1, id, "20240202", domain.ScopeOccurrence, now, 0))
+		assert.Equal(t, 1, exceptionRows(t, s, id))
+		require.NoError(t, s.Complete(1, id, now, 0))
+		require.NoError(t, s.Complete(1, id, now, 0))
+		_, err = s.Get(1, id)
+		require.ErrorIs(t, err, ErrNotFound)
+		assert.Zero(t, exceptionRows(t, s, id))
+	})
+
+	t.Run("Completions", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 10, 0, 0, 0, time.UTC)
+
+		once, err := s.Add(1, domain.Task{Date: "20240126", Title: "Позвонить", Comment: "до обеда"})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(1, once, now, 0))
+		_, err = s.Get(1, once)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		weekly, err := s.Add(1, domain.Task{Date: "20240126", Title: "Отчёт", Repeat: "d 7"})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(1, weekly, now.Add(time.Hour), 0))
+
+		completions, err := s.Completions(1, CompletionQuery{})
+		require.NoError(t, err)
+		require.Len(t, completions, 2)
+		assert.Equal(t, domain.Completion{
+			ID: completions[1].ID, TaskID: once, Date: "20240126", CompletedAt: "2024-01-26T10:00:00.000000Z",
+			Title: "Позвонить", Comment: "до обеда", Version: 1,
+		}, completions[1])
+		assert.Equal(t, weekly, completions[0].TaskID)
+		assert.Equal(t, "d 7", completions[0].Repeat)
+
+		for _, tc := range []struct {
+			q    CompletionQuery
+			want int
+		}{
+			{CompletionQuery{TaskID: weekly}, 1},
+			{CompletionQuery{DateFrom: "20240127"}, 0},
+			{CompletionQuery{DateFrom: "20240126", DateTo: "20240126"}, 2},
+			{CompletionQuery{Limit: 1}, 1},
+		} {
+			completions, err = s.Completions(1, tc.q)
+			require.NoError(t, err)
+			assert.Len(t, completions, tc.want, "%+v", tc.q)
+		}
+		completions, err = s.Completions(2, CompletionQuery{})
+		require.NoError(t, err)
+		assert.Empty(t, completions)
+		_, err = s.Completions(1, CompletionQuery{DateTo: "26.01.2024"})
+		assert.ErrorIs(t, err, ErrValidation)
+
+		// разовая задача возвращается с прежними id и датой, запись о выполнении удаляется
+		_, err = s.Reopen(2, once)
+		assert.ErrorIs(t, err, ErrNotFound)
+		task, err := s.Reopen(1, once)
+		require.NoError(t, err)
+		assert.Equal(t, domain.Task{ID: once, Date: "20240126", Title: "Позвонить", Comment: "до обеда", Version: 2, CreatedAt: task.CreatedAt}, task)
+		stored, err := s.Get(1, once)
+		require.NoError(t, err)
+		assert.Equal(t, task, stored)
+		// ETag задачи до выполнения устарел и после возврата
+		_, err = s.Update(1, domain.Task{ID: once, Date: "20240127", Title: "Позвонить"}, 1)
+		assert.ErrorIs(t, err, ErrPrecondition)
+		completions, err = s.Completions(1, CompletionQuery{TaskID: once})
+		require.NoError(t, err)
+		assert.Empty(t, completions)
+
+		_, err = s.Reopen(1, once)
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = s.Reopen(1, 999)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		last, err := s.Add(1, domain.Task{Date: "20240126", Title: "Курс", Repeat: "d 7 count:1"})
+		require.NoError(t, err)
+		require.NoError(t, s.Complete(1, last, now, 0))
+		_, err = s.Reopen(1, last)
+		assert.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("CanonicalRepeat", func(t *testing.T) {
+		s := open(t)
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Задача", Repeat: "m 07,19 05,6 until:31.12.2025"})
+		require.NoError(t, err)
+		task, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, "m 7,19 5,6 until:20251231", task.Repeat)
+
+		repeat := "rrule:freq=weekly;byday=mo,fr"
+		task, err = s.Patch(1, id, domain.TaskPatch{Repeat: &repeat}, 0)
+		require.NoError(t, err)
+		assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,FR", task.Repeat)
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		s := open(t)
+		now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+		id, err := s.Add(1, domain.Task{Date: "20240126", Title: "Задача", Repeat: "d 1"})
+		require.NoError(t, err)
+		task, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), task.Version)
+
+		task, err = s.Update(1, domain.Task{ID: id, Date: "20240126", Title: "Первая", Repeat: "d 1"}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), task.Version)
+
+		// устаревшая версия отклоняется и не меняет задачу
+		_, err = s.Update(1, domain.Task{ID: id, Date: "20240126", Title: "Вторая"}, 1)
+		assert.ErrorIs(t, err, ErrPrecondition)
+		_, err = s.Patch(1, id, domain.TaskPatch{Title: &task.Title}, 1)
+		assert.ErrorIs(t, err, ErrPrecondition)
+		assert.ErrorIs(t, s.Complete(1, id, now, 1), ErrPrecondition)
+		assert.ErrorIs(t, s.Delete(1, id, 1), ErrPrecondition)
+		stored, err := s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, task, stored)
+
+		// несуществующая задача — по-прежнему 404, а не конфликт версий
+		assert.ErrorIs(t, s.Delete(1, id+100, 1), ErrNotFound)
+
+		require.NoError(t, s.Complete(1, id, now, 2))
+		stored, err = s.Get(1, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stored.Version)
+		assert.NoError(t, s.Delete(1, id, 3))
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		s := open(t)
+		id, err := s.CreateUser("alice", "hash")
+		require.NoError(t, err)
+		_, err = s.CreateUser("alice", "other")
+		assert.ErrorIs(t, err, ErrLoginTaken)
+
+		user, err := s.UserByLogin("alice")
+		require.NoError(t, err)
+		assert.Equal(t, domain.User{ID: id, Login: "alice", PasswordHash: "hash"}, user)
+		user, err = s.UserByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", user.Login)
+
+		_, err = s.UserByLogin("bob")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = s.UserByID(id + 100)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		secret, err := s.AuthSecret()
+		require.NoError(t, err)
+		assert.Len(t, secret, 32)
+		again, err := s.AuthSecret()
+		require.NoError(t, err)
+		assert.Equal(t, secret, again)
+	})
+}
+
+// exceptionRows возвращает число хранимых исключений задачи id, в том числе
+// оставшихся после её удаления.
+func exceptionRows(t *testing.T, s Store, id int64) int {
+	switch s := s.(type) {
+	case *memoryStore:
+		return len(s.exceptions[id])
+	case *sqlStore:
+		var n int
+		require.NoError(t, s.db.QueryRow(s.dialect.rebind("SELECT count(*) FROM task_exceptions WHERE task_id = ?"), id).Scan(&n))
+		return n
+	}
+	t.Fatalf("unknown store %T", s)
+	return 0
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemory()
+	})
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		s, err := OpenSQLite(filepath.Join(t.TempDir(), "scheduler.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+// TestPostgresStore использует базу из TODO_DB_URL и очищает её таблицы.
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("TODO_DB_URL")
+	if backend, _ := backendOf(url); backend != "postgres" {
+		t.Skip("TODO_DB_URL не указывает на PostgreSQL")
+	}
+
+	testStore(t, func(t *testing.T) Store {
+		s, err := OpenPostgres(url)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+
+		_, err = s.(*sqlStore).db.Exec("TRUNCATE scheduler, users, task_history, task_exceptions, task_completions RESTART IDENTITY")
+		require.NoError(t, err)
+		return s
+	})
+}
+
+// TestQueryStoresAgree выполняет одни и те же условия поиска в памяти и в
+// SQLite: хранилища должны находить одинаковые задачи.
+func TestQueryStoresAgree(t *testing.T) {
+	sqlite, err := OpenSQLite(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlite.Close() })
+	stores := map[string]Store{"memory": NewMemory(), "sqlite": sqlite}
+
+	for _, task := range []domain.Task{
+		{Date: "20240126", Title: "Отчёт за январь", Comment: "Report", Repeat: "m 1"},
+		{Date: "20240127", Title: "ОТЧЁТ для налоговой", Repeat: "FREQ=MONTHLY;INTERVAL=3;BYDAY=-1FR"},
+		{Date: "20240128", Title: "weekly REPORT", Repeat: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{Date: "20240129", Title: "Ёжик в тумане", Comment: "скидка 50%_off", Repeat: "FREQ=WEEKLY;INTERVAL=2"},
+		{Date: "20240130", Title: "Бассейн", Repeat: "FREQ=DAILY;INTERVAL=3;COUNT=5"},
+		{Date: "20240131", Title: "День рождения", Repeat: "FREQ=YEARLY"},
+		{Date: "20240201", Title: "Планёрка", Repeat: "w 1,3"},
+		{Date: "20240202", Title: "Зарплата", Repeat: "FREQ=MONTHLY;BYMONTHDAY=10,25"},
+		{Date: "20240203", Title: "Созвон", Comment: "про ёжика"},
+	} {
+		for _, s := range stores {
+			_, err := s.Add(1, task)
+			require.NoError(t, err)
+		}
+	}
+
+	list := func(t *testing.T, s Store, q TaskQuery) []string {
+		q.Limit = 50
+		page, err := s.List(1, q)
+		require.NoError(t, err)
+		titles := make([]string, 0, len(page.Tasks))
+		for _, task := range page.Tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+
+	for in, want := range map[string]int{
+		"отчёт":            2,
+		"ОТЧЁТ":            2,
+		"report":           2,
+		"title:report":     1,
+		"ёж":               2,
+		"comment:ЁЖ":       1,
+		`"50%_off"`:        1,
+		"repeat:d":         2,
+		"repeat:w":         2,
+		"repeat:m":         2,
+		"repeat:mw":        1,
+		"repeat:y":         1,
+		"repeat:any":       8,
+		"repeat:none":      1,
+		"-repeat:w отчёт":  2,
+		"repeat:mw -отчёт": 0,
+		"report repeat:w":  1,
+		"Ёжик -comment:ёж": 1,
+	} {
+		filter, err := query.Parse(in)
+		require.NoError(t, err, in)
+		memory, sqlite := list(t, stores["memory"], TaskQuery{Filter: filter}), list(t, stores["sqlite"], TaskQuery{Filter: filter})
+		assert.Equal(t, memory, sqlite, in)
+		assert.Len(t, sqlite, want, in)
+	}
+
+	for _, kind := range repeatTypes {
+		q := TaskQuery{RepeatType: kind}
+		assert.Equal(t, list(t, stores["memory"], q), list(t, stores["sqlite"], q), kind)
+	}
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+)
+
+func (s *sqlStore) CreateUser(login, passwordHash string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(
+		s.dialect.rebind("INSERT INTO users (login, password_hash) VALUES (?, ?) RETURNING id"),
+		login, passwordHash,
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrLoginTaken
+		}
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *sqlStore) UserByLogin(login string) (user domain.User, err error) {
+	err = s.db.QueryRow(
+		s.dialect.rebind("SELECT id, login, password_hash FROM users WHERE login = ?"),
+		login,
+	).Scan(&user.ID, &user.Login, &user.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *sqlStore) UserByID(id int64) (user domain.User, err error) {
+	err = s.db.QueryRow(
+		s.dialect.rebind("SELECT id, login, password_hash FROM users WHERE id = ?"),
+		id,
+	).Scan(&user.ID, &user.Login, &user.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *sqlStore) AuthSecret() ([]byte, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM settings WHERE key = 'auth_secret'").Scan(&value)
+	if err == nil {
+		return hex.DecodeString(value)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(
+		s.dialect.rebind("INSERT INTO settings (key, value) VALUES ('auth_secret', ?) ON CONFLICT DO NOTHING"),
+		hex.EncodeToString(secret),
+	); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	// Повторное чтение на случай, если ключ успел записать другой процесс
+	if err := s.db.QueryRow("SELECT value FROM settings WHERE key = 'auth_secret'").Scan(&value); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return hex.DecodeString(value)
+}
+
+func newSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return secret, nil
+}
@@ -6,4 +6,88 @@ type Task struct {
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	// Version растёт при каждом изменении задачи; клиенту отдаётся как ETag.
+	Version int64 `json:"-"`
+	// CreatedAt — время создания в UTC, задаётся хранилищем.
+	CreatedAt string `json:"-"`
+	// Remaining — сколько повторений осталось, включая текущую дату, для
+	// правил с count:; 0 — без ограничения. Задаётся хранилищем.
+	Remaining int `json:"remaining,string,omitempty"`
+	// Anchor — от какой даты считается следующее повторение после выполнения:
+	// пусто (AnchorSchedule) — от даты задачи, AnchorCompletion — от дня выполнения.
+	Anchor string `json:"anchor,omitempty"`
+	// CatchUp — что делать с пропущенными повторениями при выполнении
+	// просроченной задачи: пусто (CatchUpSkip) — перейти к ближайшей будущей
+	// дате, CatchUpOne — к следующему повторению, CatchUpSpawn — создать
+	// отдельную задачу на каждое пропущенное.
+	CatchUp string `json:"catch_up,omitempty"`
+}
+
+// Якоря повторения.
+const (
+	AnchorSchedule   = "schedule"
+	AnchorCompletion = "completion"
+)
+
+// Политики пропущенных повторений.
+const (
+	CatchUpSkip  = "skip"
+	CatchUpOne   = "one"
+	CatchUpSpawn = "spawn"
+)
+
+// TaskEvent — запись истории задачи: перенос даты без выполнения.
+type TaskEvent struct {
+	TaskID int64  `json:"task_id,string"`
+	Action string `json:"action"`
+	// From и To — дата задачи до и после события.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// At — время события в UTC.
+	At string `json:"at"`
+}
+
+// Действия в истории задачи.
+const (
+	EventSkip   = "skip"
+	EventSnooze = "snooze"
+)
+
+// TaskException — отменённое или заменённое повторение задачи. Повторение
+// с исключением пропускается, когда задача переходит к следующей дате.
+type TaskException struct {
+	TaskID int64 `json:"task_id,string"`
+	// Date — исходная дата повторения.
+	Date string `json:"date"`
+	// OverrideID — разовая задача, заменившая повторение; 0 — повторение отменено.
+	OverrideID int64 `json:"override_id,string,omitempty"`
+}
+
+// Границы изменения повторяющейся задачи.
+const (
+	ScopeSeries     = "series"
+	ScopeFollowing  = "following"
+	ScopeOccurrence = "occurrence"
+)
+
+// Completion — запись о выполнении задачи со снимком её полей.
+type Completion struct {
+	ID     int64 `json:"id,string"`
+	TaskID int64 `json:"task_id,string"`
+	// Date — дата задачи, которую выполнили.
+	Date string `json:"date"`
+	// CompletedAt — время выполнения в UTC.
+	CompletedAt string `json:"completed_at"`
+	Title       string `json:"title"`
+	Comment     string `json:"comment"`
+	Repeat      string `json:"repeat"`
+	// Version — версия задачи при выполнении; возвращённая задача продолжает
+	// нумерацию с неё, чтобы старые ETag не совпали снова.
+	Version int64 `json:"-"`
+}
+
+type User struct {
+	ID           int64
+	Login        string
+	PasswordHash string
 }
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// TaskPatch — изменения задачи по JSON Merge Patch (RFC 7396):
+// nil — поле не передано, пустая строка — поле очищается (в том числе через null).
+type TaskPatch struct {
+	Date    *string
+	Title   *string
+	Comment *string
+	Repeat  *string
+	Anchor  *string
+	CatchUp *string
+}
+
+var ErrPatchNotObject = errors.New("merge patch must be a JSON object")
+
+// ParseTaskPatch разбирает тело PATCH-запроса. Поле id допускается и игнорируется.
+func ParseTaskPatch(data []byte) (TaskPatch, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return TaskPatch{}, ErrPatchNotObject
+	}
+
+	var p TaskPatch
+	var errs FieldErrors
+	for field, value := range raw {
+		var target **string
+		switch field {
+		case "id":
+			continue
+		case "date":
+			target = &p.Date
+		case "title":
+			target = &p.Title
+		case "comment":
+			target = &p.Comment
+		case "repeat":
+			target = &p.Repeat
+		case "anchor":
+			target = &p.Anchor
+		case "catch_up":
+			target = &p.CatchUp
+		default:
+			errs = append(errs, FieldError{field, CodeUnknown, "unknown field " + field})
+			continue
+		}
+
+		var s *string
+		if err := json.Unmarshal(value, &s); err != nil {
+			errs = append(errs, FieldError{field, CodeFormat, field + " must be a string or null"})
+			continue
+		}
+		if s == nil {
+			s = new(string)
+		}
+		*target = s
+	}
+
+	if len(errs) > 0 {
+		return TaskPatch{}, errs
+	}
+	return p, nil
+}
+
+func (p TaskPatch) Empty() bool {
+	return p.Date == nil && p.Title == nil && p.Comment == nil && p.Repeat == nil && p.Anchor == nil && p.CatchUp == nil
+}
+
+// Apply возвращает задачу t с применёнными изменениями.
+func (p TaskPatch) Apply(t Task) Task {
+	if p.Date != nil {
+		t.Date = *p.Date
+	}
+	if p.Title != nil {
+		t.Title = *p.Title
+	}
+	if p.Comment != nil {
+		t.Comment = *p.Comment
+	}
+	if p.Repeat != nil {
+		t.Repeat = *p.Repeat
+	}
+	if p.Anchor != nil {
+		t.Anchor = *p.Anchor
+	}
+	if p.CatchUp != nil {
+		t.CatchUp = *p.CatchUp
+	}
+	return t
+}
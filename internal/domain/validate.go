@@ -0,0 +1,151 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+const (
+	MaxTitleLen   = 100
+	MaxCommentLen = 500
+)
+
+// Коды ошибок полей.
+const (
+	CodeRequired       = "required"
+	CodeTooLong        = "too_long"
+	CodeFormat         = "format"
+	CodeInvalidDate    = "invalid_date"
+	CodeInvalidRepeat  = "invalid_repeat"
+	CodeUnknown        = "unknown_field"
+	CodeInvalidAnchor  = "invalid_anchor"
+	CodeInvalidCatchUp = "invalid_catch_up"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// FieldErrors — результат проверки задачи; пустой список означает отсутствие ошибок.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Err возвращает nil для пустого списка, чтобы не получить ненулевой error.
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Normalize убирает пробелы по краям заголовка и комментария и приводит
+// корректное правило повторения к каноническому виду. Якорь по расписанию
+// и политика CatchUpSkip хранятся пустой строкой.
+func (t *Task) Normalize() {
+	t.Title = strings.TrimSpace(t.Title)
+	t.Comment = strings.TrimSpace(t.Comment)
+	if t.Anchor == AnchorSchedule {
+		t.Anchor = ""
+	}
+	if t.CatchUp == CatchUpSkip {
+		t.CatchUp = ""
+	}
+	if rule, err := util.ParseRule(t.Repeat); t.Repeat != "" && err == nil {
+		t.Repeat = rule.String()
+	}
+}
+
+// Validate проверяет все поля задачи. Длина считается в символах, а не в байтах.
+func (t Task) Validate() FieldErrors {
+	var errs FieldErrors
+
+	switch title := strings.TrimSpace(t.Title); {
+	case title == "":
+		errs = append(errs, FieldError{"title", CodeRequired, "title is required"})
+	case utf8.RuneCountInString(title) > MaxTitleLen:
+		errs = append(errs, FieldError{"title", CodeTooLong, "title is too long (max 100 characters)"})
+	}
+
+	if utf8.RuneCountInString(t.Comment) > MaxCommentLen {
+		errs = append(errs, FieldError{"comment", CodeTooLong, "comment is too long (max 500 characters)"})
+	}
+
+	if fe := ValidateDate("date", t.Date); fe != nil {
+		errs = append(errs, *fe)
+	} else if fe := ValidateRepeat(t.Date, t.Repeat); fe != nil {
+		errs = append(errs, *fe)
+	}
+
+	switch t.Anchor {
+	case "", AnchorSchedule:
+	case AnchorCompletion:
+		if t.Repeat == "" {
+			errs = append(errs, FieldError{"anchor", CodeInvalidAnchor, "anchor completion requires repeat"})
+		}
+	default:
+		errs = append(errs, FieldError{"anchor", CodeInvalidAnchor, "anchor must be schedule or completion"})
+	}
+
+	switch t.CatchUp {
+	case "", CatchUpSkip:
+	case CatchUpOne, CatchUpSpawn:
+		if t.Repeat == "" {
+			errs = append(errs, FieldError{"catch_up", CodeInvalidCatchUp, "catch_up " + t.CatchUp + " requires repeat"})
+		} else if t.Anchor == AnchorCompletion {
+			// от дня выполнения пропущенных повторений не бывает
+			errs = append(errs, FieldError{"catch_up", CodeInvalidCatchUp, "catch_up " + t.CatchUp + " requires anchor schedule"})
+		}
+	default:
+		errs = append(errs, FieldError{"catch_up", CodeInvalidCatchUp, "catch_up must be skip, one or spawn"})
+	}
+
+	return errs
+}
+
+// ValidateDate проверяет дату в формате 20060102.
+func ValidateDate(field, value string) *FieldError {
+	if value == "" {
+		return &FieldError{field, CodeRequired, field + " is required"}
+	}
+	if len(value) != len(util.DateFormat) || strings.Trim(value, "0123456789") != "" {
+		return &FieldError{field, CodeFormat, field + " must be in YYYYMMDD format"}
+	}
+	if _, err := time.Parse(util.DateFormat, value); err != nil {
+		return &FieldError{field, CodeInvalidDate, field + " does not exist"}
+	}
+	return nil
+}
+
+// ValidateRepeat проверяет правило повторения относительно корректной даты date.
+// Закончившаяся серия допустима: такая задача удалится при выполнении.
+func ValidateRepeat(date, repeat string) *FieldError {
+	if repeat == "" {
+		return nil
+	}
+	rule, err := util.ParseRule(repeat)
+	if err == nil && rule.Limits.Until != "" && rule.Limits.Until < date {
+		err = errors.New("until is before the task date")
+	}
+	if err == nil {
+		// правило, не дающее ни одной даты, отклоняем
+		start, _ := time.Parse(util.DateFormat, date)
+		_, err = rule.Next(start, time.Now())
+	}
+	if err != nil {
+		return &FieldError{"repeat", CodeInvalidRepeat, "invalid repeat rule: " + err.Error()}
+	}
+	return nil
+}
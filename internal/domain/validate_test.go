@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func codes(errs FieldErrors) map[string]string {
+	m := make(map[string]string)
+	for _, e := range errs {
+		m[e.Field] = e.Code
+	}
+	return m
+}
+
+func TestValidate(t *testing.T) {
+	tbl := []struct {
+		task Task
+		want map[string]string
+	}{
+		{Task{Date: "20240126", Title: "Задача"}, map[string]string{}},
+		{Task{Date: "20240126", Title: strings.Repeat("ж", MaxTitleLen)}, map[string]string{}},
+		{Task{Date: "20240126", Title: strings.Repeat("ж", MaxTitleLen+1)}, map[string]string{"title": CodeTooLong}},
+		{Task{Date: "20240126", Title: "  "}, map[string]string{"title": CodeRequired}},
+		{Task{Date: "20240126", Title: "Задача", Comment: strings.Repeat("ж", MaxCommentLen+1)}, map[string]string{"comment": CodeTooLong}},
+		{Task{Title: "Задача"}, map[string]string{"date": CodeRequired}},
+		{Task{Date: "28.01.2024", Title: "Задача"}, map[string]string{"date": CodeFormat}},
+		{Task{Date: "20240192", Title: "Задача"}, map[string]string{"date": CodeInvalidDate}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 7"}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "w"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "mw -1:5"}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "mw 6:5"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 7 until:20240301 count:3"}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 7 until:20240127"}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 7 until:20240125"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 7 count:0"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "count:3"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=12"}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "FREQ=MONTHLY;UNTIL=20240101"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "FREQ=SECONDLY"}, map[string]string{"repeat": CodeInvalidRepeat}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 5", Anchor: AnchorCompletion}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Anchor: AnchorSchedule}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", Anchor: AnchorCompletion}, map[string]string{"anchor": CodeInvalidAnchor}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 5", Anchor: "done"}, map[string]string{"anchor": CodeInvalidAnchor}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "w 1", CatchUp: CatchUpSpawn}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", CatchUp: CatchUpSkip}, map[string]string{}},
+		{Task{Date: "20240126", Title: "Задача", CatchUp: CatchUpOne}, map[string]string{"catch_up": CodeInvalidCatchUp}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 5", Anchor: AnchorCompletion, CatchUp: CatchUpOne}, map[string]string{"catch_up": CodeInvalidCatchUp}},
+		{Task{Date: "20240126", Title: "Задача", Repeat: "d 5", CatchUp: "all"}, map[string]string{"catch_up": CodeInvalidCatchUp}},
+		{Task{Date: "20240192", Repeat: "ooops"}, map[string]string{"title": CodeRequired, "date": CodeInvalidDate}},
+	}
+	for _, v := range tbl {
+		errs := v.task.Validate()
+		assert.Equal(t, v.want, codes(errs), "%+v", v.task)
+		assert.Equal(t, len(errs) == 0, errs.Err() == nil)
+	}
+}
@@ -0,0 +1,177 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// Error — ошибка разбора запроса; Pos — номер символа (с 1), с которого
+// начинается ошибочный элемент Token.
+type Error struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d: %q", e.Msg, e.Pos, e.Token)
+}
+
+// repeatKinds — значения repeat:.
+var repeatKinds = []string{"d", "w", "m", "mw", "y", RepeatAny, RepeatNone}
+
+// token — элемент запроса: необязательный минус, имя поля и значение.
+type token struct {
+	pos   int // номер символа с 1
+	text  string
+	neg   bool
+	field string
+	value string
+}
+
+// Parse разбирает запрос. Элементы разделяются пробелами и объединяются по И:
+//
+//	слово             подстрока в заголовке или комментарии
+//	"фраза из слов"   подстрока с пробелами
+//	title:x comment:x подстрока в одном поле, значение можно взять в кавычки
+//	tag:work          заголовок или комментарий содержит #work
+//	date:01.02.2025   дата задачи; перед датой допустимы =, <, <=, >, >=,
+//	                  дата в формате 02.01.2006 или 20060102
+//	repeat:w          вид правила повторения (d, w, m, mw, y), any или none
+//	-элемент          отрицание любого из перечисленного
+//
+// Пустой запрос возвращает nil.
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var and And
+	for _, tok := range tokens {
+		e, err := tok.expr()
+		if err != nil {
+			return nil, err
+		}
+		if tok.neg {
+			e = Not{X: e}
+		}
+		and = append(and, e)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (tok token) errorf(format string, args ...any) error {
+	return &Error{Pos: tok.pos, Token: tok.text, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (tok token) expr() (Expr, error) {
+	if tok.field == "" {
+		if tok.value == "" {
+			return nil, tok.errorf("empty term")
+		}
+		return Text{Value: tok.value}, nil
+	}
+	if tok.value == "" {
+		return nil, tok.errorf("missing value for %s", tok.field)
+	}
+
+	switch tok.field {
+	case "title", "comment":
+		return Text{Field: tok.field, Value: tok.value}, nil
+	case "tag":
+		return Text{Value: "#" + strings.TrimPrefix(tok.value, "#")}, nil
+	case "date":
+		return tok.date()
+	case "repeat":
+		for _, kind := range repeatKinds {
+			if tok.value == kind {
+				return Repeat{Kind: kind}, nil
+			}
+		}
+		return nil, tok.errorf("repeat must be one of %s", strings.Join(repeatKinds, ", "))
+	}
+	return nil, tok.errorf("unknown field %s", tok.field)
+}
+
+func (tok token) date() (Expr, error) {
+	op, value := "=", tok.value
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, candidate) {
+			op, value = candidate, value[len(candidate):]
+			break
+		}
+	}
+
+	for _, layout := range []string{"02.01.2006", util.DateFormat} {
+		if d, err := time.Parse(layout, value); err == nil {
+			return Date{Op: op, Value: d.Format(util.DateFormat)}, nil
+		}
+	}
+	return nil, tok.errorf("invalid date %q, expected 02.01.2006", value)
+}
+
+// lex делит запрос на элементы. Имя поля — латинские буквы перед двоеточием;
+// иначе двоеточие считается частью слова (например, 10:30).
+func lex(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		tok := token{pos: start + 1}
+		if runes[i] == '-' {
+			tok.neg = true
+			i++
+		}
+
+		// имя поля
+		j := i
+		for j < len(runes) && runes[j] < utf8.RuneSelf && unicode.IsLetter(runes[j]) {
+			j++
+		}
+		if j > i && j < len(runes) && runes[j] == ':' {
+			tok.field = strings.ToLower(string(runes[i:j]))
+			i = j + 1
+		}
+
+		// значение: в кавычках или до пробела
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &Error{Pos: start + 1, Token: string(runes[start:]), Msg: "unterminated quote"}
+			}
+			tok.value = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			tok.value = string(runes[i:end])
+			i = end
+		}
+
+		tok.text = string(runes[start:i])
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
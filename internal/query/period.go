@@ -0,0 +1,127 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// Period — диапазон дат включительно в формате 20060102; пустая граница
+// диапазон не ограничивает.
+type Period struct {
+	From string
+	To   string
+}
+
+// Contains сообщает, попадает ли дата date (20060102) в диапазон.
+func (p Period) Contains(date string) bool {
+	return (p.From == "" || date >= p.From) && (p.To == "" || date <= p.To)
+}
+
+// Intersect возвращает пересечение диапазонов. Если они не пересекаются,
+// From окажется больше To.
+func (p Period) Intersect(o Period) Period {
+	if o.From > p.From {
+		p.From = o.From
+	}
+	if o.To != "" && (p.To == "" || o.To < p.To) {
+		p.To = o.To
+	}
+	return p
+}
+
+// dateLayouts — форматы дат в строке поиска.
+var dateLayouts = []string{"02.01.2006", "2006-01-02"}
+
+// relativeDays — выражения «следующие N дней»; N подставляется вместо %d.
+var relativeDays = []string{"next %d days", "следующие %d дней", "ближайшие %d дней"}
+
+// ParsePeriod распознаёт в строке поиска дату или диапазон дат:
+//
+//	01.03.2025, 2025-03-01                 одна дата
+//	01.03.2025-15.03.2025, 2025-03-01..2025-03-15  диапазон включительно
+//	today, tomorrow, yesterday              сегодня, завтра, вчера
+//	this week, next week                    неделя с понедельника
+//	this month                              текущий месяц
+//	next 7 days                             сегодня и ещё 6 дней
+//	overdue                                 всё, что раньше сегодняшнего дня
+//
+// Ключевые слова принимаются и по-русски. Относительные выражения считаются
+// от now, поэтому now нужно передавать в часовом поясе пользователя.
+// ok == false — строка не является датой и ищется как текст.
+func ParsePeriod(s string, now time.Time) (p Period, ok bool) {
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if s == "" {
+		return Period{}, false
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	span := func(from time.Time, days int) Period {
+		return Period{From: from.Format(util.DateFormat), To: from.AddDate(0, 0, days-1).Format(util.DateFormat)}
+	}
+	// понедельник текущей недели
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+
+	switch s {
+	case "today", "сегодня":
+		return span(today, 1), true
+	case "tomorrow", "завтра":
+		return span(today.AddDate(0, 0, 1), 1), true
+	case "yesterday", "вчера":
+		return span(today.AddDate(0, 0, -1), 1), true
+	case "this week", "эта неделя", "на этой неделе":
+		return span(monday, 7), true
+	case "next week", "следующая неделя", "на следующей неделе":
+		return span(monday.AddDate(0, 0, 7), 7), true
+	case "this month", "этот месяц", "в этом месяце":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return Period{From: first.Format(util.DateFormat), To: first.AddDate(0, 1, -1).Format(util.DateFormat)}, true
+	case "overdue", "просрочено", "просроченные":
+		return Period{To: today.AddDate(0, 0, -1).Format(util.DateFormat)}, true
+	}
+
+	for _, pattern := range relativeDays {
+		prefix, suffix, _ := strings.Cut(pattern, "%d")
+		if rest, found := strings.CutPrefix(s, prefix); found {
+			if number, found := strings.CutSuffix(rest, suffix); found {
+				if n, err := strconv.Atoi(number); err == nil && n > 0 && n <= 366 {
+					return span(today, n), true
+				}
+			}
+		}
+	}
+
+	if d, ok := parseDate(s); ok {
+		return Period{From: d, To: d}, true
+	}
+
+	// диапазон: пробуем каждое «..» и «-» как разделитель, в ISO-датах тоже есть дефисы
+	for i := 0; i < len(s); i++ {
+		var sep int
+		switch {
+		case strings.HasPrefix(s[i:], ".."):
+			sep = 2
+		case s[i] == '-':
+			sep = 1
+		default:
+			continue
+		}
+		from, okFrom := parseDate(strings.TrimSpace(s[:i]))
+		to, okTo := parseDate(strings.TrimSpace(s[i+sep:]))
+		if okFrom && okTo {
+			return Period{From: from, To: to}, true
+		}
+	}
+	return Period{}, false
+}
+
+func parseDate(s string) (string, bool) {
+	for _, layout := range dateLayouts {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d.Format(util.DateFormat), true
+		}
+	}
+	return "", false
+}
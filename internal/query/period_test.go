@@ -0,0 +1,58 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePeriod(t *testing.T) {
+	// среда, поздний вечер по Москве — в UTC уже другой час, но тот же день
+	now := time.Date(2025, 3, 12, 23, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	for in, want := range map[string]Period{
+		"26.01.2024":              {"20240126", "20240126"},
+		"2024-01-26":              {"20240126", "20240126"},
+		"01.03.2025-15.03.2025":   {"20250301", "20250315"},
+		"01.03.2025 - 15.03.2025": {"20250301", "20250315"},
+		"2025-03-01-2025-03-15":   {"20250301", "20250315"},
+		"2025-03-01..2025-03-15":  {"20250301", "20250315"},
+		"15.03.2025-01.03.2025":   {"20250315", "20250301"},
+		"today":                   {"20250312", "20250312"},
+		"Сегодня":                 {"20250312", "20250312"},
+		"tomorrow":                {"20250313", "20250313"},
+		"yesterday":               {"20250311", "20250311"},
+		"this week":               {"20250310", "20250316"},
+		"This  Week":              {"20250310", "20250316"},
+		"на следующей неделе":     {"20250317", "20250323"},
+		"this month":              {"20250301", "20250331"},
+		"overdue":                 {"", "20250311"},
+		"next 7 days":             {"20250312", "20250318"},
+		"ближайшие 30 дней":       {"20250312", "20250410"},
+	} {
+		got, ok := ParsePeriod(in, now)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "УК", "31.02.2025", "next 0 days", "next week please", "01.03.2025-", "10-20"} {
+		_, ok := ParsePeriod(in, now)
+		assert.False(t, ok, in)
+	}
+
+	// воскресенье относится к уходящей неделе
+	sunday := time.Date(2025, 3, 16, 12, 0, 0, 0, time.UTC)
+	got, _ := ParsePeriod("this week", sunday)
+	assert.Equal(t, Period{"20250310", "20250316"}, got)
+}
+
+func TestPeriodIntersect(t *testing.T) {
+	p := Period{From: "20250301", To: "20250315"}
+	assert.Equal(t, p, p.Intersect(Period{}))
+	assert.Equal(t, Period{"20250305", "20250310"}, p.Intersect(Period{From: "20250305", To: "20250310"}))
+	assert.Equal(t, Period{"20250301", "20250311"}, Period{To: "20250311"}.Intersect(Period{From: "20250301"}))
+	assert.True(t, p.Contains("20250301"))
+	assert.False(t, p.Contains("20250316"))
+	assert.True(t, Period{}.Contains("20250316"))
+}
@@ -0,0 +1,172 @@
+// Package query разбирает язык поиска задач, например
+//
+//	date:>=01.02.2025 repeat:w tag:work "точная фраза" -лишнее
+//
+// в дерево условий, которое компилируется в параметризованный SQL
+// и умеет проверять задачу в памяти.
+package query
+
+import (
+	"strings"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/Kovarniykrab/finishGolang/internal/util"
+)
+
+// Expr — условие поиска.
+type Expr interface {
+	// SQL возвращает условие для WHERE с плейсхолдерами ? и его аргументы.
+	SQL() (string, []any)
+	// Match проверяет задачу так же, как условие SQL.
+	Match(t domain.Task) bool
+}
+
+// And истинно, если истинны все условия.
+type And []Expr
+
+func (a And) SQL() (string, []any) {
+	parts := make([]string, 0, len(a))
+	var args []any
+	for _, e := range a {
+		cond, eargs := e.SQL()
+		parts = append(parts, cond)
+		args = append(args, eargs...)
+	}
+	return "(" + strings.Join(parts, " AND ") + ")", args
+}
+
+func (a And) Match(t domain.Task) bool {
+	for _, e := range a {
+		if !e.Match(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Not отрицает условие: -слово, -tag:x.
+type Not struct {
+	X Expr
+}
+
+func (n Not) SQL() (string, []any) {
+	cond, args := n.X.SQL()
+	return "NOT " + cond, args
+}
+
+func (n Not) Match(t domain.Task) bool {
+	return !n.X.Match(t)
+}
+
+// Text ищет подстроку без учёта регистра в поле Field ("title" или
+// "comment"), пустое Field — в заголовке или комментарии. В SQL строки
+// сравниваются через lower(), которая должна понимать не только латиницу.
+type Text struct {
+	Field string
+	Value string
+}
+
+func (x Text) SQL() (string, []any) {
+	pattern := "%" + escapeLike(strings.ToLower(x.Value)) + "%"
+	if x.Field != "" {
+		return "(lower(" + x.Field + `) LIKE ? ESCAPE '\')`, []any{pattern}
+	}
+	return `(lower(title) LIKE ? ESCAPE '\' OR lower(comment) LIKE ? ESCAPE '\')`, []any{pattern, pattern}
+}
+
+func (x Text) Match(t domain.Task) bool {
+	value := strings.ToLower(x.Value)
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), value) }
+	switch x.Field {
+	case "title":
+		return contains(t.Title)
+	case "comment":
+		return contains(t.Comment)
+	}
+	return contains(t.Title) || contains(t.Comment)
+}
+
+// Date сравнивает дату задачи с Value (20060102) оператором Op: =, <, <=, >, >=.
+type Date struct {
+	Op    string
+	Value string
+}
+
+func (d Date) SQL() (string, []any) {
+	return "(date " + d.Op + " ?)", []any{d.Value}
+}
+
+func (d Date) Match(t domain.Task) bool {
+	switch d.Op {
+	case "<":
+		return t.Date < d.Value
+	case "<=":
+		return t.Date <= d.Value
+	case ">":
+		return t.Date > d.Value
+	case ">=":
+		return t.Date >= d.Value
+	}
+	return t.Date == d.Value
+}
+
+// Repeat отбирает задачи по виду правила повторения: d, w, m, mw, y,
+// RepeatAny — любое правило, RepeatNone — без повторения. Правила RRULE
+// относятся к виду по util.Rule.BaseKind.
+type Repeat struct {
+	Kind string
+}
+
+const (
+	RepeatAny  = "any"
+	RepeatNone = "none"
+)
+
+func (r Repeat) SQL() (string, []any) {
+	switch r.Kind {
+	case RepeatAny:
+		return "(repeat <> '')", nil
+	case RepeatNone:
+		return "(repeat = '')", nil
+	}
+
+	cond, args := "repeat = ? OR repeat LIKE ?", []any{r.Kind, r.Kind + " %"}
+	// RRULE хранится в каноническом виде (util.RRule.String), FREQ — первым
+	const byDay = "%;BYDAY=%"
+	switch r.Kind {
+	case util.KindDays:
+		cond += " OR repeat LIKE ? OR (repeat LIKE ? AND repeat NOT LIKE ?)"
+		args = append(args, "FREQ=DAILY%", "FREQ=WEEKLY%", byDay)
+	case util.KindWeekdays:
+		cond += " OR (repeat LIKE ? AND repeat LIKE ?)"
+		args = append(args, "FREQ=WEEKLY%", byDay)
+	case util.KindMonthDays:
+		cond += " OR (repeat LIKE ? AND repeat NOT LIKE ?)"
+		args = append(args, "FREQ=MONTHLY%", byDay)
+	case util.KindNthWeekday:
+		cond += " OR (repeat LIKE ? AND repeat LIKE ?)"
+		args = append(args, "FREQ=MONTHLY%", byDay)
+	case util.KindYearly:
+		cond += " OR repeat LIKE ?"
+		args = append(args, "FREQ=YEARLY%")
+	}
+	return "(" + cond + ")", args
+}
+
+func (r Repeat) Match(t domain.Task) bool {
+	switch r.Kind {
+	case RepeatAny:
+		return t.Repeat != ""
+	case RepeatNone:
+		return t.Repeat == ""
+	}
+	if rule, err := util.ParseRule(t.Repeat); err == nil {
+		return rule.BaseKind() == r.Kind
+	}
+	return t.Repeat == r.Kind || strings.HasPrefix(t.Repeat, r.Kind+" ")
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы % и _ искались буквально.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
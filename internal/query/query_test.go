@@ -0,0 +1,122 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/Kovarniykrab/finishGolang/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Expr
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"молоко", Text{Value: "молоко"}},
+		{`"купить молоко"`, Text{Value: "купить молоко"}},
+		{"встреча 10:30", And{Text{Value: "встреча"}, Text{Value: "10:30"}}},
+		{`title:"отчёт за месяц"`, Text{Field: "title", Value: "отчёт за месяц"}},
+		{"Comment:хлеб", Text{Field: "comment", Value: "хлеб"}},
+		{"tag:work", Text{Value: "#work"}},
+		{"tag:#work", Text{Value: "#work"}},
+		{"date:01.02.2025", Date{Op: "=", Value: "20250201"}},
+		{"date:>=01.02.2025", Date{Op: ">=", Value: "20250201"}},
+		{"date:<20250201", Date{Op: "<", Value: "20250201"}},
+		{"repeat:w", Repeat{Kind: "w"}},
+		{"-repeat:none", Not{X: Repeat{Kind: RepeatNone}}},
+		{
+			`date:>=01.02.2025 repeat:w tag:work "exact phrase" -excluded`,
+			And{
+				Date{Op: ">=", Value: "20250201"},
+				Repeat{Kind: "w"},
+				Text{Value: "#work"},
+				Text{Value: "exact phrase"},
+				Not{X: Text{Value: "excluded"}},
+			},
+		},
+	} {
+		got, err := Parse(tc.in)
+		require.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		pos   int
+		token string
+	}{
+		{"молоко -", 8, "-"},
+		{`молоко "купить`, 8, `"купить`},
+		{"молоко date:31.02.2025", 8, "date:31.02.2025"},
+		{"date:>=вчера", 1, "date:>=вчера"},
+		{"ёж repeat:q", 4, "repeat:q"},
+		{"priority:1", 1, "priority:1"},
+		{"tag:", 1, "tag:"},
+		{`""`, 1, `""`},
+	} {
+		_, err := Parse(tc.in)
+		var qerr *Error
+		require.ErrorAs(t, err, &qerr, tc.in)
+		assert.Equal(t, tc.pos, qerr.Pos, tc.in)
+		assert.Equal(t, tc.token, qerr.Token, tc.in)
+		assert.Contains(t, err.Error(), "position", tc.in)
+	}
+}
+
+func TestSQL(t *testing.T) {
+	e, err := Parse(`date:>=01.02.2025 repeat:w -"50%_off" title:x`)
+	require.NoError(t, err)
+
+	sql, args := e.SQL()
+	assert.Equal(t, `((date >= ?) AND (repeat = ? OR repeat LIKE ? OR (repeat LIKE ? AND repeat LIKE ?)) AND NOT (lower(title) LIKE ? ESCAPE '\' OR lower(comment) LIKE ? ESCAPE '\') AND (lower(title) LIKE ? ESCAPE '\'))`, sql)
+	assert.Equal(t, []any{"20250201", "w", "w %", "FREQ=WEEKLY%", "%;BYDAY=%", `%50\%\_off%`, `%50\%\_off%`, "%x%"}, args)
+}
+
+func TestMatch(t *testing.T) {
+	task := domain.Task{Date: "20250203", Title: "Отчёт #work", Comment: "за январь", Repeat: "w 1"}
+	for in, want := range map[string]bool{
+		"отчёт":                 true,
+		"ОТЧЁТ":                 true,
+		"отчёт1":                false,
+		"январь":                true,
+		"title:январь":          false,
+		"comment:январь":        true,
+		"tag:work":              true,
+		"tag:home":              false,
+		"date:03.02.2025":       true,
+		"date:>03.02.2025":      false,
+		"date:<=03.02.2025":     true,
+		"repeat:w":              true,
+		"repeat:m":              false,
+		"repeat:any":            true,
+		"repeat:none":           false,
+		"-repeat:none tag:work": true,
+		`"Отчёт #work" -"за январь"`: false,
+	} {
+		e, err := Parse(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, e.Match(task), in)
+	}
+
+	// правила RRULE относятся к виду краткого синтаксиса
+	for repeat, kind := range map[string]string{
+		"d 5":                               "d",
+		"FREQ=DAILY;INTERVAL=2":             "d",
+		"FREQ=WEEKLY;INTERVAL=2":            "d",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO":   "w",
+		"FREQ=MONTHLY;BYMONTHDAY=1,15":      "m",
+		"FREQ=MONTHLY;INTERVAL=3;BYDAY=2TU": "mw",
+		"FREQ=YEARLY;BYMONTH=3":             "y",
+		"mw 2:2 count:5":                    "mw",
+	} {
+		for _, k := range []string{"d", "w", "m", "mw", "y"} {
+			task := domain.Task{Repeat: repeat}
+			assert.Equal(t, k == kind, Repeat{Kind: k}.Match(task), "%s repeat:%s", repeat, k)
+		}
+	}
+}
@@ -1,22 +1,98 @@
 package server
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/Kovarniykrab/finishGolang/internal/api"
+	"github.com/Kovarniykrab/finishGolang/internal/config"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
 )
 
-func Start(port int, db *sql.DB) error {
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 15 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 60 * time.Second
+	// ShutdownTimeout — сколько ждать завершения активных запросов при остановке.
+	ShutdownTimeout = 10 * time.Second
+)
+
+type Server struct {
+	http  *http.Server
+	store database.Store
+}
+
+func New(cfg *config.Config, store database.Store) *Server {
 	mux := http.NewServeMux()
 
 	// API обработчики
-	api.RegisterHandlers(mux, db)
+	api.RegisterHandlers(mux, store, cfg.Location)
 
 	// Статические файлы (только для корневого пути)
-	mux.Handle("/", http.FileServer(http.Dir("./web")))
+	mux.Handle("/", http.FileServer(http.Dir(cfg.WebDir)))
+
+	return &Server{
+		http: &http.Server{
+			Addr:              fmt.Sprintf(":%d", cfg.Port),
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+			ReadTimeout:       readTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
+		},
+		store: store,
+	}
+}
+
+// Start запускает сервер и блокируется до отмены ctx.
+func Start(ctx context.Context, cfg *config.Config, store database.Store) error {
+	return New(cfg, store).Run(ctx)
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		s.store.Close()
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve принимает соединения на ln до отмены ctx, затем дожидается
+// завершения активных запросов (не дольше ShutdownTimeout) и закрывает хранилище.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.http.Serve(ln)
+	}()
+
+	fmt.Printf("Сервер запущен на %s\n", ln.Addr())
+
+	var serveErr error
+	select {
+	case serveErr = <-errCh:
+	case <-ctx.Done():
+		log.Println("Остановка сервера...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+
+		if err := s.http.Shutdown(shutdownCtx); err != nil {
+			serveErr = fmt.Errorf("не удалось дождаться завершения запросов: %w", err)
+			s.http.Close()
+		}
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			serveErr = errors.Join(serveErr, err)
+		}
+	}
 
-	fmt.Printf("Сервер запущен на порту %d\n", port)
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+	if err := s.store.Close(); err != nil {
+		serveErr = errors.Join(serveErr, fmt.Errorf("не удалось закрыть БД: %w", err))
+	}
+	return serveErr
 }
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Kovarniykrab/finishGolang/internal/config"
+	"github.com/Kovarniykrab/finishGolang/internal/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type closeTracker struct {
+	database.Store
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return c.Store.Close()
+}
+
+func TestServeShutdown(t *testing.T) {
+	store := &closeTracker{Store: database.NewMemory()}
+	cfg := &config.Config{WebDir: t.TempDir(), Location: time.UTC}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- New(cfg, store).Serve(ctx, ln)
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/tasks", addr))
+	require.NoError(t, err)
+	var body map[string][]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotNil(t, body["tasks"])
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(ShutdownTimeout + time.Second):
+		t.Fatal("сервер не остановился")
+	}
+	assert.True(t, store.closed)
+
+	_, err = net.DialTimeout("tcp", addr, time.Second)
+	assert.Error(t, err)
+}
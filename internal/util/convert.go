@@ -0,0 +1,117 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotCompact — правило RRULE нельзя записать в кратком синтаксисе.
+var ErrNotCompact = errors.New("правило нельзя записать в кратком синтаксисе")
+
+// ToRRule переводит правило из краткого синтаксиса в RRULE:
+//
+//	d 7         FREQ=DAILY;INTERVAL=7
+//	w 1,3,5     FREQ=WEEKLY;BYDAY=MO,WE,FR
+//	m 1,-1 3    FREQ=MONTHLY;BYMONTH=3;BYMONTHDAY=1,-1
+//	mw 2:2      FREQ=MONTHLY;BYDAY=2TU
+//	y           FREQ=YEARLY
+//
+// Модификаторы until: и count: становятся UNTIL и COUNT. Правило RRULE
+// возвращается в каноническом виде. NextDate даёт для обеих записей одни и те
+// же даты, в том числе для start в будущем и для серий с 29 февраля.
+func ToRRule(repeat string) (string, error) {
+	rule, err := ParseRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	if rule.Kind == KindRRule {
+		return rule.RRule.String(), nil
+	}
+	if rule.Limits.Until != "" && rule.Limits.Count != 0 {
+		return "", fmt.Errorf("в RRULE нельзя указать и until, и count: %s", repeat)
+	}
+
+	r := RRule{Interval: 1, Wkst: 1, ByMonth: rule.Months, Until: rule.Limits.Until, Count: rule.Limits.Count}
+	switch rule.Kind {
+	case KindDays:
+		r.Freq = FreqDaily
+		r.Interval = rule.Interval
+	case KindYearly:
+		r.Freq = FreqYearly
+	case KindWeekdays:
+		r.Freq = FreqWeekly
+		for _, wd := range rule.Weekdays {
+			r.ByDay = append(r.ByDay, ByDay{Weekday: wd})
+		}
+	case KindMonthDays:
+		r.Freq = FreqMonthly
+		r.ByMonthDay = rule.Days
+	case KindNthWeekday:
+		r.Freq = FreqMonthly
+		r.ByDay = rule.Nth
+	}
+	return r.String(), nil
+}
+
+// FromRRule переводит правило RRULE в краткий синтаксис. Если это невозможно
+// (например, INTERVAL у WEEKLY с BYDAY или BYSETPOS), возвращает ErrNotCompact.
+// Правило в кратком синтаксисе возвращается в каноническом виде.
+func FromRRule(repeat string) (string, error) {
+	if !IsRRule(repeat) {
+		rule, err := ParseRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return rule.String(), nil
+	}
+
+	r, err := ParseRRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	if len(r.BySetPos) > 0 {
+		return "", ErrNotCompact
+	}
+
+	rule := Rule{Months: r.ByMonth, Limits: Limits{Until: r.Until, Count: r.Count}}
+	switch {
+	case r.Freq == FreqDaily && len(r.ByDay)+len(r.ByMonthDay)+len(r.ByMonth) == 0 && r.Interval <= 400:
+		rule.Kind, rule.Interval = KindDays, r.Interval
+	case r.Freq == FreqWeekly && len(r.ByDay)+len(r.ByMonth) == 0 && r.Interval*7 <= 400:
+		rule.Kind, rule.Interval = KindDays, r.Interval*7
+	case r.Freq == FreqWeekly && len(r.ByDay) > 0 && len(r.ByMonth) == 0 && r.Interval == 1:
+		rule.Kind = KindWeekdays
+		for _, d := range r.ByDay {
+			rule.Weekdays = append(rule.Weekdays, d.Weekday)
+		}
+	case r.Freq == FreqMonthly && r.Interval == 1 && len(r.ByMonthDay) > 0 && len(r.ByDay) == 0 && compactMonthDays(r.ByMonthDay):
+		rule.Kind, rule.Days = KindMonthDays, r.ByMonthDay
+	case r.Freq == FreqMonthly && r.Interval == 1 && len(r.ByMonthDay) == 0 && nthWeekdays(r.ByDay):
+		rule.Kind, rule.Nth = KindNthWeekday, r.ByDay
+	case r.Freq == FreqYearly && r.Interval == 1 && len(r.ByDay)+len(r.ByMonthDay)+len(r.ByMonth) == 0:
+		rule.Kind = KindYearly
+	default:
+		return "", ErrNotCompact
+	}
+	return rule.String(), nil
+}
+
+// compactMonthDays — дни месяца, допустимые в правиле m: от -2 до 31.
+func compactMonthDays(days []int) bool {
+	for _, d := range days {
+		if d < -2 {
+			return false
+		}
+	}
+	return true
+}
+
+// nthWeekdays — у каждого дня BYDAY есть номер, как требует правило mw.
+func nthWeekdays(days []ByDay) bool {
+	for _, d := range days {
+		if d.N == 0 {
+			return false
+		}
+	}
+	return len(days) > 0
+}
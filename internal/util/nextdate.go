@@ -1,10 +1,9 @@
 package util
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"net/http"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -12,157 +11,79 @@ const (
 	DateFormat string = "20060102"
 )
 
-func NextDate(now time.Time, date string, repeat string) (string, error) {
-	Start, err := time.Parse(DateFormat, date)
-	if err != nil {
-		return "", fmt.Errorf("неверный формат начальной даты: %v", err)
-	}
-
-	Repeat := strings.Split(repeat, " ")
-
-	if len(Repeat) != 0 {
-		switch Repeat[0] {
-		case "d":
-			if len(Repeat) < 2 {
-				return "", fmt.Errorf("неверный формат repeat для d: %s", repeat)
-			}
-			days, err := strconv.Atoi(Repeat[1])
-			if err != nil || days > 400 || days < 1 {
-				return "", fmt.Errorf("неверное количество дней: %v", err)
-			}
-			Start = Start.AddDate(0, 0, days)
-			for Start.Format(DateFormat) <= now.Format(DateFormat) {
-				Start = Start.AddDate(0, 0, days)
-			}
-			return Start.Format(DateFormat), nil
-		case "y":
-			if len(Repeat) != 1 {
-				return "", fmt.Errorf("неверный формат repeat для y: %s", repeat)
-			}
-			Start = Start.AddDate(1, 0, 0)
-			for Start.Format(DateFormat) <= now.Format(DateFormat) {
-				Start = Start.AddDate(1, 0, 0)
-			}
-			return Start.Format(DateFormat), nil
-		case "w":
-			if len(Repeat) != 2 {
-				return "", fmt.Errorf("неверный формат repeat для w: %s", repeat)
-			}
-			week := strings.Split(Repeat[1], ",")
-
-			var targetDays []int
-
-			for _, w := range week {
-				day, err := strconv.Atoi(w)
-				if err != nil || day < 1 || day > 7 {
-					return "", fmt.Errorf("неверный день недели: %v", err)
-				}
-				targetDays = append(targetDays, day)
-			}
-
-			for {
-				weekDay := int(Start.Weekday())
-				if weekDay == 0 {
-					weekDay = 7
-				}
-
-				for _, day := range targetDays {
-					if weekDay == day && Start.Format(DateFormat) > now.Format(DateFormat) {
-						return Start.Format(DateFormat), nil
-					}
-				}
-				Start = Start.AddDate(0, 0, 1)
-			}
-		case "m":
-			if len(Repeat) < 2 {
-				return "", fmt.Errorf("неверный формат repeat для m: %s", repeat)
-			}
-
-			var months []int
-			if len(Repeat) > 2 {
-				monthStr := strings.Split(Repeat[2], ",")
-				for _, m := range monthStr {
-					month, err := strconv.Atoi(m)
-					if err != nil || month < 1 || month > 12 {
-						return "", fmt.Errorf("неверный месяц: %v", err)
-					}
-					months = append(months, month)
-				}
-			}
-
-			days := strings.Split(Repeat[1], ",")
-			for {
-				dayMatch := false
-				for _, d := range days {
-					day, err := strconv.Atoi(d)
-					if err != nil || day > 31 || day < -2 {
-						return "", fmt.Errorf("неверный день: %v", err)
-					}
-					if day < 0 {
-						lastDay := time.Date(Start.Year(), Start.Month()+1, 0, 0, 0, 0, 0, Start.Location()).Day()
-						calculatedDay := lastDay + day + 1
-						if calculatedDay < 1 {
-							return "", fmt.Errorf("некорректный день месяца: %d", calculatedDay)
-						}
-						day = calculatedDay
-					}
-					if Start.Day() == day {
-						dayMatch = true
-						break
-					}
-				}
+// horizonYears — на сколько лет вперёд ищется следующая дата. Правило,
+// не давшее даты за это время, считается не дающим дат вовсе.
+const horizonYears = 100
 
-				monthMatched := len(months) == 0
-				for _, m := range months {
-					if int(Start.Month()) == m {
-						monthMatched = true
-						break
-					}
-				}
+// ctxCheckPeriods — через сколько перебранных периодов (для m и mw — месяцев)
+// поиск даты проверяет, не отменён ли контекст.
+const ctxCheckPeriods = 256
 
-				if dayMatch && monthMatched && Start.Format(DateFormat) > now.Format(DateFormat) {
-					return Start.Format(DateFormat), nil
-				}
-				Start = Start.AddDate(0, 0, 1)
+// ErrNoDates — правило не даёт ни одной даты, например "m 31 2".
+var ErrNoDates = errors.New("правило не даёт дат")
 
-			}
+// NextDate возвращает первую дату повторения по правилу repeat позже now.
+// Правило записывается в кратком синтаксисе или в формате RRULE (см. ParseRule)
+// и может заканчиваться модификаторами until: и count: (см. SplitRepeat);
+// если следующая дата позже until, возвращается ErrSeriesEnded. count
+// учитывается хранилищем, NextDate его только проверяет.
+func NextDate(now time.Time, date string, repeat string) (string, error) {
+	return NextDateContext(context.Background(), now, date, repeat)
+}
 
-		default:
-			return "", fmt.Errorf("правило повторения указано в неправильном формате: %v", repeat)
-		}
+// NextDateContext — NextDate, которую можно прервать через ctx: отмена
+// проверяется и во время перебора дат. Поиск даты в любом случае ограничен
+// horizonYears годами; если дата не найдена, возвращается ошибка,
+// оборачивающая ErrNoDates.
+func NextDateContext(ctx context.Context, now time.Time, date string, repeat string) (string, error) {
+	start, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат начальной даты: %v", err)
 	}
+	rule, err := ParseRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return rule.NextDateContext(ctx, start, now)
+}
 
-	return now.Format(DateFormat), nil
+// NextDate возвращает следующую дату правила в формате DateFormat или
+// ErrSeriesEnded, если она позже until.
+func (r Rule) NextDate(start, after time.Time) (string, error) {
+	return r.NextDateContext(context.Background(), start, after)
 }
 
-func NextDateHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// NextDateContext — Rule.NextDate, которую можно прервать через ctx.
+func (r Rule) NextDateContext(ctx context.Context, start, after time.Time) (string, error) {
+	next, err := r.NextContext(ctx, start, after)
+	if err != nil {
+		return "", err
 	}
-
-	nowStr := r.URL.Query().Get("now")
-	date := r.URL.Query().Get("date")
-	repeat := r.URL.Query().Get("repeat")
-
-	var now time.Time
-	if nowStr == "" {
-		now = time.Now().UTC()
-	} else {
-		var err error
-		now, err = time.Parse(DateFormat, nowStr)
-		if err != nil {
-			http.Error(w, "Invalid 'now' date format", http.StatusBadRequest)
-			return
-		}
+	if s := next.Format(DateFormat); r.Limits.Until == "" || s <= r.Limits.Until {
+		return s, nil
 	}
+	return "", ErrSeriesEnded
+}
 
-	nextDate, err := NextDate(now, date, repeat)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// monthDaysPossible сообщает, есть ли хотя бы один из дней days (отрицательные —
+// с конца месяца) хотя бы в одном из месяцев months; пустой months — любой месяц.
+func monthDaysPossible(days, months []int) bool {
+	if len(months) == 0 {
+		return true
 	}
-
-	w.Header().Set("Content-Type", "text/plain")
-	w.Write([]byte(nextDate))
+	for _, day := range days {
+		if day < 0 {
+			return true
+		}
+		for _, m := range months {
+			// год високосный, чтобы 29 февраля считалось возможным
+			if day <= daysIn(2024, time.Month(m)) {
+				return true
+			}
+		}
+	}
+	return false
 }
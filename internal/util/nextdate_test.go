@@ -0,0 +1,161 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextDateImpossible(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	for _, repeat := range []string{
+		"m 31 2",
+		"m 30,31 2",
+		"m 31 4,6,9,11",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		"FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=1MO",
+	} {
+		_, err := NextDate(now, "20240126", repeat)
+		assert.ErrorIs(t, err, ErrNoDates, repeat)
+	}
+
+	_, err := NextDate(now, "20240126", "m 0")
+	assert.Error(t, err)
+}
+
+func TestNextDateRare(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		date, repeat, want string
+	}{
+		{"20240229", "m 29 2", "20280229"},
+		{"20250301", "mw 5:1 2", "20440229"},
+		{"00010101", "d 1", "20250302"},
+		{"00010101", "d 400", "20251216"},
+		{"00010101", "w 3", "20250305"},
+		{"00010101", "m -1", "20250331"},
+		{"00010101", "y", "20260101"},
+	} {
+		next, err := NextDate(now, tc.date, tc.repeat)
+		require.NoError(t, err, tc.repeat)
+		assert.Equal(t, tc.want, next, "%s from %s", tc.repeat, tc.date)
+	}
+}
+
+func TestNextDateCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NextDateContext(ctx, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "20250301", "m 29 2")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// отмена во время перебора: правило не даёт дат, и без проверки ctx
+	// поиск дошёл бы до горизонта
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = NextDate(now, "20240101", "FREQ=DAILY;INTERVAL=7;BYDAY=TU")
+	require.ErrorIs(t, err, ErrNoDates)
+	ctx = &cancelAfter{Context: context.Background(), calls: 1}
+	_, err = NextDateContext(ctx, now, "20240101", "FREQ=DAILY;INTERVAL=7;BYDAY=TU")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// cancelAfter — контекст, который считается отменённым после calls вызовов Err.
+type cancelAfter struct {
+	context.Context
+	calls int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.calls--; c.calls < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+// TestRuleMatchesStepwise сверяет Rule.Next с прежним перебором по дням.
+func TestRuleMatchesStepwise(t *testing.T) {
+	rules := []string{
+		"d 1", "d 7", "d 30", "d 400", "y",
+		"w 1", "w 7", "w 1,3,5", "w 6,2",
+		"m 1", "m 31", "m -1", "m -2", "m 29 2", "m 15,-1", "m 10,17 12,8,1", "m 31 1,4",
+		"mw 1:1", "mw -1:5", "mw 5:2", "mw 2:2,-1:7", "mw 5:1 2", "mw -5:3 1,2",
+	}
+	start := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	for _, repeat := range rules {
+		rule, err := ParseRule(repeat)
+		require.NoError(t, err, repeat)
+		for d := 0; d < 500; d += 11 {
+			date := start.AddDate(0, 0, d)
+			for n := -40; n < 800; n += 29 {
+				now := date.AddDate(0, 0, n)
+				want, err := stepwiseNextDate(context.Background(), now, date.Format(DateFormat), repeat)
+				require.NoError(t, err, repeat)
+				got, err := rule.Next(date, now)
+				require.NoError(t, err, repeat)
+				require.Equal(t, want, got.Format(DateFormat), "%s from %s, now %s", repeat, date.Format(DateFormat), now.Format(DateFormat))
+			}
+		}
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	for in, want := range map[string]string{
+		"d 10":                         "d 10",
+		"w 1,3,5":                      "w 1,3,5",
+		"m 07,19 05,6":                 "m 7,19 5,6",
+		"mw 02:2,-1:05":                "mw 2:2,-1:5",
+		"y":                            "y",
+		"d 7 count:3 until:31.12.2025": "d 7 until:20251231 count:3",
+		"rrule:freq=weekly;byday=mo":   "FREQ=WEEKLY;BYDAY=MO",
+	} {
+		rule, err := ParseRule(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, rule.String(), in)
+	}
+
+	rule, err := ParseRule("w 1 count:5")
+	require.NoError(t, err)
+	assert.Equal(t, "w 1 until:20240131", rule.WithLimits(Limits{Until: "20240131"}).String())
+	rule, err = ParseRule("FREQ=DAILY;COUNT=5")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=DAILY;COUNT=3", rule.WithLimits(Limits{Count: 3}).String())
+	assert.Equal(t, "FREQ=DAILY;COUNT=5", rule.String())
+
+	for _, in := range []string{"", "d", "d 7 3", "w 1 2", "m 1 2 3", "m 0", "k 1", "count:3"} {
+		_, err := ParseRule(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func BenchmarkNextDate(b *testing.B) {
+	now := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct{ date, repeat string }{
+		{"20240101", "d 3"},
+		{"20000101", "w 2,4"},
+		{"20000101", "m -1,15 2,8"},
+		{"20250601", "m 29 2"},
+		{"20000101", "mw -1:5 12"},
+	} {
+		date, _ := time.Parse(DateFormat, tc.date)
+		b.Run(tc.repeat+"/Rule", func(b *testing.B) {
+			rule, err := ParseRule(tc.repeat)
+			require.NoError(b, err)
+			for i := 0; i < b.N; i++ {
+				rule.Next(date, now)
+			}
+		})
+		b.Run(tc.repeat+"/ParseAndNext", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NextDate(now, tc.date, tc.repeat)
+			}
+		})
+		b.Run(tc.repeat+"/Stepwise", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				stepwiseNextDate(context.Background(), now, tc.date, tc.repeat)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxOccurrences — наибольшее число дат, которое возвращает Occurrences.
+const MaxOccurrences = 500
+
+// Occurrences возвращает до limit (не больше MaxOccurrences) следующих дат
+// повторения позже now — те, которые задача с датой date будет принимать
+// при выполнении: каждая следующая дата вычисляется от предыдущей.
+// Если to не пустая, возвращаются только даты не позже to. Серия
+// заканчивается по until:, а count: ограничивает число дат серии вместе
+// с самой date и датами до now.
+func Occurrences(ctx context.Context, now time.Time, date, repeat string, limit int, to string) ([]string, error) {
+	start, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return nil, fmt.Errorf("неверный формат начальной даты: %v", err)
+	}
+	rule, err := ParseRule(repeat)
+	if err != nil {
+		return nil, err
+	}
+	limit = min(limit, MaxOccurrences)
+
+	now = dateOnly(now)
+	after := now
+	if rule.Limits.Count > 0 && start.Before(now) {
+		// count: тратится и на прошедшие даты, поэтому идём от date
+		after = start
+	}
+	// сама date — первая дата серии
+	seen := 1
+
+	var dates []string
+	for len(dates) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if rule.Limits.Count > 0 && seen >= rule.Limits.Count {
+			break
+		}
+		next, err := rule.NextDateContext(ctx, start, after)
+		if errors.Is(err, ErrSeriesEnded) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if to != "" && next > to {
+			break
+		}
+		if next != date {
+			seen++
+		}
+		// даты строго возрастают, поэтому цикл ограничен limit и count:
+		after, _ = time.Parse(DateFormat, next)
+		if after.After(now) {
+			dates = append(dates, next)
+		}
+	}
+	return dates, nil
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOccurrences(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		date, repeat string
+		limit        int
+		to           string
+		want         []string
+	}{
+		{"20240101", "m -1,15 2,8", 6, "", []string{"20240215", "20240229", "20240815", "20240831", "20250215", "20250228"}},
+		{"20240120", "d 5", 3, "", []string{"20240130", "20240204", "20240209"}},
+		{"20240101", "w 1,5", 10, "20240205", []string{"20240129", "20240202", "20240205"}},
+		{"20240101", "FREQ=MONTHLY;BYDAY=2TU", 2, "", []string{"20240213", "20240312"}},
+		{"20240120", "d 5 count:2", 10, "", nil},
+		{"20240120", "d 5 count:3", 10, "", []string{"20240130"}},
+		{"20240301", "d 5 count:3", 10, "", []string{"20240306", "20240311"}},
+		{"20240201", "w 4 count:2", 10, "", []string{"20240201", "20240208"}},
+		{"20240120", "d 5 until:20240205", 10, "", []string{"20240130", "20240204"}},
+		{"20240120", "d 5 until:20240125", 10, "", nil},
+	} {
+		got, err := Occurrences(context.Background(), now, tc.date, tc.repeat, tc.limit, tc.to)
+		require.NoError(t, err, tc.repeat)
+		assert.Equal(t, tc.want, got, tc.repeat)
+	}
+
+	// now в другом поясе сравнивается по дате, а не по моменту времени
+	east := time.Date(2024, 1, 26, 1, 0, 0, 0, time.FixedZone("UTC+14", 14*3600))
+	got, err := Occurrences(context.Background(), east, "20240121", "d 5 count:3", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240131"}, got)
+
+	got, err = Occurrences(context.Background(), now, "20240101", "d 1", MaxOccurrences+100, "")
+	require.NoError(t, err)
+	assert.Len(t, got, MaxOccurrences)
+
+	_, err = Occurrences(context.Background(), now, "20240101", "k 1", 10, "")
+	assert.Error(t, err)
+}
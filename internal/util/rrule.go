@@ -0,0 +1,495 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Частоты RRULE. Задачи привязаны к датам, поэтому HOURLY и меньшие не поддерживаются.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// weekdayNames — дни недели RRULE с понедельника, индекс+1 — номер дня в кратком синтаксисе.
+var weekdayNames = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// ByDay — элемент BYDAY: день недели 1..7 с понедельника и необязательный
+// номер N (2TU — второй вторник, -1FR — последняя пятница).
+type ByDay struct {
+	N       int
+	Weekday int
+}
+
+func (d ByDay) String() string {
+	s := weekdayNames[d.Weekday-1]
+	if d.N != 0 {
+		s = strconv.Itoa(d.N) + s
+	}
+	return s
+}
+
+// RRule — правило повторения iCalendar (RFC 5545) для дат без времени.
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []ByDay
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	Count      int
+	// Until — последняя дата серии в формате DateFormat.
+	Until string
+	// Wkst — первый день недели 1..7, по умолчанию понедельник.
+	Wkst int
+}
+
+// IsRRule сообщает, записано ли правило в формате RRULE, а не в кратком синтаксисе.
+func IsRRule(repeat string) bool {
+	return strings.Contains(repeat, "=")
+}
+
+// ParseRRule разбирает строку вида "FREQ=MONTHLY;BYDAY=2TU;COUNT=10",
+// префикс "RRULE:" необязателен, регистр не важен.
+func ParseRRule(s string) (RRule, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "RRULE:")
+
+	r := RRule{Interval: 1, Wkst: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return RRule{}, fmt.Errorf("неверный элемент RRULE: %q", part)
+		}
+		if seen[key] {
+			return RRule{}, fmt.Errorf("%s указан дважды", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = value
+			default:
+				err = fmt.Errorf("неподдерживаемая частота: %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parseBounded(value, 1, 1000)
+		case "COUNT":
+			r.Count, err = parseBounded(value, 1, 10000)
+		case "UNTIL":
+			// время (20251231T235959Z) отбрасываем: задачи привязаны к датам
+			date, _, _ := strings.Cut(value, "T")
+			r.Until, err = parseUntil(date)
+		case "WKST":
+			r.Wkst = slices.Index(weekdayNames, value) + 1
+			if r.Wkst == 0 {
+				err = fmt.Errorf("неверный день недели: %s", value)
+			}
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var d ByDay
+				if d, err = parseByDay(v); err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, d)
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseList(value, -31, 31)
+		case "BYMONTH":
+			r.ByMonth, err = parseList(value, 1, 12)
+		case "BYSETPOS":
+			r.BySetPos, err = parseList(value, -366, 366)
+		default:
+			err = fmt.Errorf("неподдерживаемый элемент RRULE: %s", key)
+		}
+		if err != nil {
+			return RRule{}, err
+		}
+	}
+
+	if r.Freq == "" {
+		return RRule{}, fmt.Errorf("в RRULE нет FREQ: %s", s)
+	}
+	if r.Count != 0 && r.Until != "" {
+		return RRule{}, fmt.Errorf("COUNT и UNTIL нельзя указывать вместе")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == FreqWeekly {
+		return RRule{}, fmt.Errorf("BYMONTHDAY нельзя использовать с FREQ=WEEKLY")
+	}
+	if len(r.ByMonth) > 0 && len(r.ByMonthDay) > 0 && !monthDaysPossible(r.ByMonthDay, r.ByMonth) {
+		return RRule{}, fmt.Errorf("%w: в месяцах BYMONTH нет дней BYMONTHDAY", ErrNoDates)
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay)+len(r.ByMonthDay)+len(r.ByMonth) == 0 {
+		return RRule{}, fmt.Errorf("BYSETPOS требует BYDAY, BYMONTHDAY или BYMONTH")
+	}
+	for _, d := range r.ByDay {
+		if d.N == 0 {
+			continue
+		}
+		if r.Freq != FreqMonthly && r.Freq != FreqYearly {
+			return RRule{}, fmt.Errorf("номер дня в BYDAY допустим только с MONTHLY и YEARLY: %s", d)
+		}
+		if r.Freq == FreqMonthly && (d.N > 5 || d.N < -5) {
+			return RRule{}, fmt.Errorf("неверный номер дня недели в месяце: %s", d)
+		}
+	}
+	return r, nil
+}
+
+// String возвращает правило в каноническом виде, без префикса RRULE:.
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.Wkst > 1 {
+		parts = append(parts, "WKST="+weekdayNames[r.Wkst-1])
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != "" {
+		parts = append(parts, "UNTIL="+r.Until)
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next возвращает первую дату серии, начатой в start, позже after. Сам start
+// входит в серию, только если правило задаёт даты фильтрами BYMONTH,
+// BYMONTHDAY или BYDAY и start под них подходит — так же, как у правил
+// w, m и mw; у правил без фильтров, как у d и y, серия идёт после start.
+// COUNT и UNTIL не учитываются — их проверяют NextDate и хранилище.
+func (r RRule) Next(start, after time.Time) (time.Time, error) {
+	return r.NextContext(context.Background(), start, after)
+}
+
+// NextContext — Next, которую можно прервать через ctx: перебор периодов
+// до горизонта проверяет ctx каждые ctxCheckPeriods периодов.
+func (r RRule) NextContext(ctx context.Context, start, after time.Time) (time.Time, error) {
+	start, after = dateOnly(start), dateOnly(after)
+	from := after
+	if from.Before(start) {
+		from = start
+	}
+	horizon := from.AddDate(horizonYears, 0, 0)
+	withStart := r.filtered()
+
+	first := r.periodStart(start)
+	// пропускаем периоды, целиком лежащие до from
+	k := r.periodsBetween(first, from)/r.Interval - 1
+	if k < 0 {
+		k = 0
+	}
+	for i := 0; ; i, k = i+1, k+1 {
+		if i%ctxCheckPeriods == 0 {
+			if err := ctx.Err(); err != nil {
+				return time.Time{}, err
+			}
+		}
+		period := r.period(first, k*r.Interval)
+		if period.After(horizon) {
+			return time.Time{}, fmt.Errorf("%w в ближайшие %d лет: %s", ErrNoDates, horizonYears, r)
+		}
+		for _, d := range r.expand(period, start) {
+			if d.After(from) || withStart && d.Equal(start) && d.After(after) {
+				return d, nil
+			}
+		}
+	}
+}
+
+// filtered сообщает, задают ли даты серии фильтры BYMONTH, BYMONTHDAY или BYDAY.
+func (r RRule) filtered() bool {
+	return len(r.ByMonth) > 0 || len(r.ByMonthDay) > 0 || len(r.ByDay) > 0
+}
+
+// periodStart возвращает начало периода FREQ, содержащего дату d.
+func (r RRule) periodStart(d time.Time) time.Time {
+	switch r.Freq {
+	case FreqWeekly:
+		return d.AddDate(0, 0, -((isoWeekday(d) - r.Wkst + 7) % 7))
+	case FreqMonthly:
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case FreqYearly:
+		return time.Date(d.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return d
+}
+
+// period возвращает начало периода, отстоящего от first на n периодов FREQ.
+func (r RRule) period(first time.Time, n int) time.Time {
+	switch r.Freq {
+	case FreqWeekly:
+		return first.AddDate(0, 0, 7*n)
+	case FreqMonthly:
+		return first.AddDate(0, n, 0)
+	case FreqYearly:
+		return first.AddDate(n, 0, 0)
+	}
+	return first.AddDate(0, 0, n)
+}
+
+// periodsBetween — число целых периодов FREQ от first до d.
+func (r RRule) periodsBetween(first, d time.Time) int {
+	days := int((d.Unix() - first.Unix()) / 86400)
+	switch r.Freq {
+	case FreqWeekly:
+		return days / 7
+	case FreqMonthly:
+		return (d.Year()-first.Year())*12 + int(d.Month()) - int(first.Month())
+	case FreqYearly:
+		return d.Year() - first.Year()
+	}
+	return days
+}
+
+// expand возвращает отсортированные даты правила в периоде, начинающемся с period.
+func (r RRule) expand(period, start time.Time) []time.Time {
+	var dates []time.Time
+	switch r.Freq {
+	case FreqDaily:
+		if r.dayMatches(period) {
+			dates = []time.Time{period}
+		}
+	case FreqWeekly:
+		for i := 0; i < 7; i++ {
+			d := period.AddDate(0, 0, i)
+			if r.monthMatches(d) && r.weekdayMatches(d, start) {
+				dates = append(dates, d)
+			}
+		}
+	case FreqMonthly:
+		if r.monthMatches(period) {
+			dates = r.monthDates(period.Year(), period.Month(), start)
+		}
+	case FreqYearly:
+		dates = r.yearDates(period.Year(), start)
+	}
+	return setPos(dates, r.BySetPos)
+}
+
+// dayMatches проверяет дату правила DAILY по фильтрам BYMONTH, BYMONTHDAY и BYDAY.
+func (r RRule) dayMatches(d time.Time) bool {
+	if !r.monthMatches(d) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !slices.Contains(r.ByMonthDay, d.Day()) &&
+		!slices.Contains(r.ByMonthDay, d.Day()-daysIn(d.Year(), d.Month())-1) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(b ByDay) bool { return b.Weekday == isoWeekday(d) }) {
+		return false
+	}
+	return true
+}
+
+func (r RRule) monthMatches(d time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, int(d.Month()))
+}
+
+// weekdayMatches — для WEEKLY без BYDAY повторяется день недели начала серии.
+func (r RRule) weekdayMatches(d, start time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return isoWeekday(d) == isoWeekday(start)
+	}
+	return slices.ContainsFunc(r.ByDay, func(b ByDay) bool { return b.Weekday == isoWeekday(d) })
+}
+
+// monthDates возвращает даты правила в месяце. Без BYMONTHDAY и BYDAY
+// повторяется число начала серии; месяцы без такого числа пропускаются.
+func (r RRule) monthDates(year int, month time.Month, start time.Time) []time.Time {
+	last := daysIn(year, month)
+	inMonth := make([]bool, last+1)
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			if md < 0 {
+				md = last + md + 1
+			}
+			if md >= 1 && md <= last {
+				inMonth[md] = true
+			}
+		}
+		if len(r.ByDay) > 0 {
+			// BYDAY сужает BYMONTHDAY
+			byDay := r.byDayIn(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), last)
+			for i := range inMonth {
+				inMonth[i] = inMonth[i] && byDay[i]
+			}
+		}
+	case len(r.ByDay) > 0:
+		inMonth = r.byDayIn(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), last)
+	default:
+		if start.Day() <= last {
+			inMonth[start.Day()] = true
+		}
+	}
+
+	var dates []time.Time
+	for day, ok := range inMonth {
+		if ok {
+			dates = append(dates, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+		}
+	}
+	return dates
+}
+
+// yearDates возвращает даты правила YEARLY в году. Номера в BYDAY без
+// BYMONTH и BYMONTHDAY считаются от начала или конца года, иначе — месяца.
+// Без фильтров серия, как у правила y, после 29 февраля идёт 1 марта.
+func (r RRule) yearDates(year int, start time.Time) []time.Time {
+	if !r.filtered() {
+		if year == start.Year() {
+			return []time.Time{start}
+		}
+		return []time.Time{start.AddDate(1, 0, 0).AddDate(year-start.Year()-1, 0, 0)}
+	}
+	if len(r.ByDay) > 0 && len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 {
+		first := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		var dates []time.Time
+		for i, ok := range r.byDayIn(first, first.AddDate(1, 0, -1).YearDay()) {
+			if ok {
+				dates = append(dates, first.AddDate(0, 0, i-1))
+			}
+		}
+		return dates
+	}
+
+	months := r.ByMonth
+	if len(months) == 0 {
+		months = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	}
+	months = slices.Sorted(slices.Values(months))
+
+	var dates []time.Time
+	for _, m := range months {
+		dates = append(dates, r.monthDates(year, time.Month(m), start)...)
+	}
+	return dates
+}
+
+// byDayIn отмечает дни диапазона из n дней, начиная с first, подходящие под BYDAY;
+// индекс — номер дня с 1.
+func (r RRule) byDayIn(first time.Time, n int) []bool {
+	marks := make([]bool, n+1)
+	for i := 1; i <= n; i++ {
+		wd := isoWeekday(first.AddDate(0, 0, i-1))
+		fromStart := (i-1)/7 + 1
+		fromEnd := -((n-i)/7 + 1)
+		for _, b := range r.ByDay {
+			if b.Weekday == wd && (b.N == 0 || b.N == fromStart || b.N == fromEnd) {
+				marks[i] = true
+				break
+			}
+		}
+	}
+	return marks
+}
+
+// setPos оставляет из отсортированных дат периода только позиции BYSETPOS.
+func setPos(dates []time.Time, positions []int) []time.Time {
+	if len(positions) == 0 {
+		return dates
+	}
+	var picked []time.Time
+	for i, d := range dates {
+		for _, p := range positions {
+			if p == i+1 || p == i-len(dates) {
+				picked = append(picked, d)
+				break
+			}
+		}
+	}
+	return picked
+}
+
+func parseByDay(s string) (ByDay, error) {
+	if len(s) < 2 {
+		return ByDay{}, fmt.Errorf("неверный день недели в BYDAY: %q", s)
+	}
+	wd := slices.Index(weekdayNames, s[len(s)-2:]) + 1
+	if wd == 0 {
+		return ByDay{}, fmt.Errorf("неверный день недели в BYDAY: %q", s)
+	}
+	d := ByDay{Weekday: wd}
+	if num := s[:len(s)-2]; num != "" {
+		n, err := strconv.Atoi(num)
+		if err != nil || n == 0 || n > 53 || n < -53 {
+			return ByDay{}, fmt.Errorf("неверный номер дня недели в BYDAY: %q", s)
+		}
+		d.N = n
+	}
+	return d, nil
+}
+
+// parseList разбирает список чисел через запятую в диапазоне [min, max] без нуля.
+func parseList(s string, min, max int) ([]int, error) {
+	var list []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("неверное значение: %s", v)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func parseBounded(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("неверное значение: %s", s)
+	}
+	return n, nil
+}
+
+func joinInts(list []int) string {
+	parts := make([]string, len(list))
+	for i, n := range list {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+// isoWeekday возвращает день недели 1..7 с понедельника.
+func isoWeekday(d time.Time) int {
+	if d.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(d.Weekday())
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
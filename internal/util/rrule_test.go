@@ -0,0 +1,167 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRRuleNext(t *testing.T) {
+	now := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		date, rule, want string
+	}{
+		{"20240126", "FREQ=DAILY", "20240127"},
+		{"20240101", "FREQ=DAILY;INTERVAL=10", "20240131"},
+		{"20240201", "FREQ=DAILY;INTERVAL=10", "20240211"},
+		{"20240101", "RRULE:FREQ=WEEKLY", "20240129"},
+		{"20240101", "freq=weekly;interval=2;byday=tu,th", "20240130"},
+		{"20240101", "FREQ=WEEKLY;BYDAY=MO,WE,FR", "20240129"},
+		{"20240101", "FREQ=MONTHLY", "20240201"},
+		{"20240131", "FREQ=MONTHLY", "20240331"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=-1", "20240131"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=2TU", "20240213"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=-1FR;BYMONTH=3,9", "20240329"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", "20240913"},
+		{"20240101", "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15", "20240415"},
+		{"20230301", "FREQ=YEARLY", "20240301"},
+		{"20200229", "FREQ=YEARLY", "20240301"},
+		{"20240229", "FREQ=YEARLY", "20250301"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "20241128"},
+		{"20240101", "FREQ=YEARLY;BYDAY=20MO", "20240513"},
+		{"20240101", "FREQ=YEARLY;BYMONTHDAY=1;BYMONTH=1,7", "20240701"},
+		{"20240301", "FREQ=DAILY;BYDAY=SA,SU", "20240302"},
+		{"18000101", "FREQ=DAILY;INTERVAL=7", "20240131"},
+	} {
+		r, err := ParseRRule(tc.rule)
+		require.NoError(t, err, tc.rule)
+		start, _ := time.Parse(DateFormat, tc.date)
+		next, err := r.Next(start, now)
+		require.NoError(t, err, tc.rule)
+		assert.Equal(t, tc.want, next.Format(DateFormat), "%s from %s", tc.rule, tc.date)
+	}
+}
+
+func TestRRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+		"FREQ=DAILY;UNTIL=2025",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=2TU",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ=DAILY;",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+	} {
+		_, err := ParseRRule(rule)
+		assert.Error(t, err, rule)
+	}
+
+	// 31-е число никогда не бывает первым понедельником — ошибка по горизонту
+	r, err := ParseRRule("FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=1MO")
+	require.NoError(t, err)
+	_, err = r.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.ErrorIs(t, err, ErrNoDates)
+}
+
+func TestSplitRRule(t *testing.T) {
+	_, limits, err := SplitRepeat("FREQ=WEEKLY;BYDAY=MO;UNTIL=20250131T235959Z")
+	require.NoError(t, err)
+	assert.Equal(t, Limits{Until: "20250131"}, limits)
+
+	_, limits, err = SplitRepeat("FREQ=WEEKLY;COUNT=5")
+	require.NoError(t, err)
+	assert.Equal(t, Limits{Count: 5}, limits)
+}
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		compact, rrule string
+	}{
+		{"d 1", "FREQ=DAILY"},
+		{"d 7", "FREQ=DAILY;INTERVAL=7"},
+		{"y", "FREQ=YEARLY"},
+		{"w 1,3,5", "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{"m 1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"m 15 3,9", "FREQ=MONTHLY;BYMONTH=3,9;BYMONTHDAY=15"},
+		{"mw 2:2,-1:5", "FREQ=MONTHLY;BYDAY=2TU,-1FR"},
+		{"mw 1:1 12", "FREQ=MONTHLY;BYMONTH=12;BYDAY=1MO"},
+		{"d 3 until:20251231", "FREQ=DAILY;INTERVAL=3;UNTIL=20251231"},
+		{"w 7 count:10", "FREQ=WEEKLY;BYDAY=SU;COUNT=10"},
+	} {
+		rrule, err := ToRRule(tc.compact)
+		require.NoError(t, err, tc.compact)
+		assert.Equal(t, tc.rrule, rrule, tc.compact)
+
+		compact, err := FromRRule(tc.rrule)
+		require.NoError(t, err, tc.rrule)
+		assert.Equal(t, tc.compact, compact, tc.rrule)
+	}
+
+	compact, err := FromRRule("FREQ=WEEKLY;INTERVAL=2")
+	require.NoError(t, err)
+	assert.Equal(t, "d 14", compact)
+	rrule, err := ToRRule("rrule:freq=monthly;bymonthday=1")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;BYMONTHDAY=1", rrule)
+
+	for _, rule := range []string{
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+		"FREQ=MONTHLY;INTERVAL=2",
+		"FREQ=MONTHLY;BYMONTHDAY=-10",
+		"FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=1",
+		"FREQ=YEARLY;BYMONTH=3",
+	} {
+		_, err := FromRRule(rule)
+		assert.ErrorIs(t, err, ErrNotCompact, rule)
+	}
+
+	_, err = ToRRule("d 1 until:20251231 count:3")
+	assert.Error(t, err)
+	_, err = ToRRule("k 1")
+	assert.Error(t, err)
+}
+
+// TestConvertNextDate сверяет даты NextDate у правила и его перевода.
+func TestConvertNextDate(t *testing.T) {
+	rules := []string{
+		"d 1", "d 7", "y", "w 1", "w 1,3,5", "w 7 count:10",
+		"m 1,-1", "m 15", "m 29 2", "m 15 3,9", "m 31 1,4",
+		"mw 2:3", "mw 2:2,-1:5", "mw 1:1 12", "d 3 until:20251231",
+	}
+	start := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	var dates []time.Time
+	for d := 0; d < 500; d += 11 {
+		dates = append(dates, start.AddDate(0, 0, d))
+	}
+	// будущее начало серии и 29 февраля
+	dates = append(dates, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+
+	for _, compact := range rules {
+		rrule, err := ToRRule(compact)
+		require.NoError(t, err, compact)
+		back, err := FromRRule(rrule)
+		require.NoError(t, err, rrule)
+		for _, date := range dates {
+			for n := -40; n < 800; n += 29 {
+				now := date.AddDate(0, 0, n)
+				want, wantErr := NextDate(now, date.Format(DateFormat), compact)
+				for _, repeat := range []string{rrule, back} {
+					got, err := NextDate(now, date.Format(DateFormat), repeat)
+					require.Equal(t, wantErr, err, "%s from %s, now %s", repeat, date.Format(DateFormat), now.Format(DateFormat))
+					require.Equal(t, want, got, "%s (%s) from %s, now %s", repeat, compact, date.Format(DateFormat), now.Format(DateFormat))
+				}
+			}
+		}
+	}
+}
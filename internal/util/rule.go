@@ -0,0 +1,328 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Виды правил повторения.
+const (
+	KindDays       = "d"
+	KindWeekdays   = "w"
+	KindMonthDays  = "m"
+	KindNthWeekday = "mw"
+	KindYearly     = "y"
+	KindRRule      = "rrule"
+)
+
+// Rule — разобранное и проверенное правило повторения.
+type Rule struct {
+	Kind string
+	// Interval — число дней для d.
+	Interval int
+	// Weekdays — дни недели 1..7 с понедельника для w.
+	Weekdays []int
+	// Days — дни месяца для m, -1 и -2 — последний и предпоследний.
+	Days []int
+	// Nth — n-е дни недели месяца для mw.
+	Nth []ByDay
+	// Months — месяцы 1..12 для m и mw, пустой — любой месяц.
+	Months []int
+	// RRule — правило в формате RRULE для KindRRule.
+	RRule *RRule
+	// Limits — модификаторы until: и count: либо UNTIL и COUNT из RRULE.
+	Limits Limits
+}
+
+// ParseRule разбирает и проверяет правило повторения в кратком синтаксисе
+// или в формате RRULE, включая модификаторы until: и count:.
+func ParseRule(repeat string) (Rule, error) {
+	rest, limits, err := SplitRepeat(repeat)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	if IsRRule(rest) {
+		r, err := ParseRRule(rest)
+		if err != nil {
+			return Rule{}, err
+		}
+		return Rule{Kind: KindRRule, RRule: &r, Limits: limits}, nil
+	}
+
+	rule := Rule{Kind: KindDays, Limits: limits}
+	fields := strings.Split(rest, " ")
+	switch fields[0] {
+	case "d":
+		if len(fields) != 2 {
+			return Rule{}, fmt.Errorf("неверный формат repeat для d: %s", repeat)
+		}
+		days, err := strconv.Atoi(fields[1])
+		if err != nil || days > 400 || days < 1 {
+			return Rule{}, fmt.Errorf("неверное количество дней: %s", fields[1])
+		}
+		rule.Interval = days
+
+	case "y":
+		if len(fields) != 1 {
+			return Rule{}, fmt.Errorf("неверный формат repeat для y: %s", repeat)
+		}
+		rule.Kind = KindYearly
+
+	case "w":
+		if len(fields) != 2 {
+			return Rule{}, fmt.Errorf("неверный формат repeat для w: %s", repeat)
+		}
+		rule.Kind = KindWeekdays
+		for _, w := range strings.Split(fields[1], ",") {
+			day, err := strconv.Atoi(w)
+			if err != nil || day < 1 || day > 7 {
+				return Rule{}, fmt.Errorf("неверный день недели: %s", w)
+			}
+			rule.Weekdays = append(rule.Weekdays, day)
+		}
+
+	case "m":
+		if len(fields) < 2 || len(fields) > 3 {
+			return Rule{}, fmt.Errorf("неверный формат repeat для m: %s", repeat)
+		}
+		rule.Kind = KindMonthDays
+		for _, d := range strings.Split(fields[1], ",") {
+			day, err := strconv.Atoi(d)
+			if err != nil || day > 31 || day < -2 || day == 0 {
+				return Rule{}, fmt.Errorf("неверный день: %s", d)
+			}
+			rule.Days = append(rule.Days, day)
+		}
+		if rule.Months, err = parseMonths(fields); err != nil {
+			return Rule{}, err
+		}
+		if !monthDaysPossible(rule.Days, rule.Months) {
+			return Rule{}, fmt.Errorf("%w: в указанных месяцах нет дней %s", ErrNoDates, fields[1])
+		}
+
+	case "mw":
+		// mw <n>:<день недели>[,...] [месяцы]: n — номер дня недели в месяце
+		// (1..5) или с конца месяца (-1 — последний), день недели 1..7.
+		if len(fields) < 2 || len(fields) > 3 {
+			return Rule{}, fmt.Errorf("неверный формат repeat для mw: %s", repeat)
+		}
+		rule.Kind = KindNthWeekday
+		for _, pair := range strings.Split(fields[1], ",") {
+			nStr, wdStr, ok := strings.Cut(pair, ":")
+			if !ok {
+				return Rule{}, fmt.Errorf("неверный формат mw, ожидается номер:день недели: %s", pair)
+			}
+			n, err := strconv.Atoi(nStr)
+			if err != nil || n == 0 || n > 5 || n < -5 {
+				return Rule{}, fmt.Errorf("неверный номер недели: %s", nStr)
+			}
+			weekday, err := strconv.Atoi(wdStr)
+			if err != nil || weekday < 1 || weekday > 7 {
+				return Rule{}, fmt.Errorf("неверный день недели: %s", wdStr)
+			}
+			rule.Nth = append(rule.Nth, ByDay{N: n, Weekday: weekday})
+		}
+		if rule.Months, err = parseMonths(fields); err != nil {
+			return Rule{}, err
+		}
+
+	default:
+		return Rule{}, fmt.Errorf("правило повторения указано в неправильном формате: %v", repeat)
+	}
+	return rule, nil
+}
+
+// parseMonths разбирает необязательный список месяцев — третий элемент правила.
+func parseMonths(fields []string) ([]int, error) {
+	if len(fields) < 3 {
+		return nil, nil
+	}
+	var months []int
+	for _, m := range strings.Split(fields[2], ",") {
+		month, err := strconv.Atoi(m)
+		if err != nil || month < 1 || month > 12 {
+			return nil, fmt.Errorf("неверный месяц: %s", m)
+		}
+		months = append(months, month)
+	}
+	return months, nil
+}
+
+// BaseKind возвращает вид правила в кратком синтаксисе: для RRULE — по FREQ,
+// WEEKLY и MONTHLY с BYDAY — w и mw, WEEKLY без BYDAY — d.
+func (r Rule) BaseKind() string {
+	if r.Kind != KindRRule {
+		return r.Kind
+	}
+	byDay := len(r.RRule.ByDay) > 0
+	switch r.RRule.Freq {
+	case FreqWeekly:
+		if byDay {
+			return KindWeekdays
+		}
+	case FreqMonthly:
+		if byDay {
+			return KindNthWeekday
+		}
+		return KindMonthDays
+	case FreqYearly:
+		return KindYearly
+	}
+	return KindDays
+}
+
+// String возвращает правило в каноническом виде: числа без ведущих нулей,
+// until: в формате 20060102, RRULE — как RRule.String. Порядок дней
+// и месяцев сохраняется.
+func (r Rule) String() string {
+	if r.Kind == KindRRule {
+		return r.RRule.String()
+	}
+
+	var s string
+	switch r.Kind {
+	case KindDays:
+		s = "d " + strconv.Itoa(r.Interval)
+	case KindYearly:
+		s = "y"
+	case KindWeekdays:
+		s = "w " + joinInts(r.Weekdays)
+	case KindMonthDays:
+		s = "m " + joinInts(r.Days)
+	case KindNthWeekday:
+		pairs := make([]string, len(r.Nth))
+		for i, d := range r.Nth {
+			pairs[i] = strconv.Itoa(d.N) + ":" + strconv.Itoa(d.Weekday)
+		}
+		s = "mw " + strings.Join(pairs, ",")
+	}
+	if len(r.Months) > 0 {
+		s += " " + joinInts(r.Months)
+	}
+	if r.Limits.Until != "" {
+		s += " until:" + r.Limits.Until
+	}
+	if r.Limits.Count > 0 {
+		s += " count:" + strconv.Itoa(r.Limits.Count)
+	}
+	return s
+}
+
+// WithLimits возвращает правило с модификаторами limits вместо текущих;
+// для RRULE они становятся UNTIL и COUNT.
+func (r Rule) WithLimits(limits Limits) Rule {
+	r.Limits = limits
+	if r.RRule != nil {
+		rr := *r.RRule
+		rr.Until, rr.Count = limits.Until, limits.Count
+		r.RRule = &rr
+	}
+	return r
+}
+
+// Next возвращает первую дату правила позже after для задачи с датой start.
+// Для d и y даты отсчитываются от start, для w, m и mw дата start
+// подходит и сама, если она позже after. until: и count: не учитываются.
+func (r Rule) Next(start, after time.Time) (time.Time, error) {
+	return r.NextContext(context.Background(), start, after)
+}
+
+// NextContext — Next, которую можно прервать через ctx.
+func (r Rule) NextContext(ctx context.Context, start, after time.Time) (time.Time, error) {
+	start, after = dateOnly(start), dateOnly(after)
+
+	switch r.Kind {
+	case KindRRule:
+		return r.RRule.NextContext(ctx, start, after)
+
+	case KindDays:
+		k := 1
+		if !after.Before(start) {
+			k = int((after.Unix()-start.Unix())/86400)/r.Interval + 1
+		}
+		return start.AddDate(0, 0, k*r.Interval), nil
+
+	case KindYearly:
+		// AddDate переносит 29 февраля на 1 марта, дальше дата не меняется
+		next := start.AddDate(1, 0, 0)
+		if !next.After(after) {
+			years := after.Year() - next.Year()
+			if next = next.AddDate(years, 0, 0); !next.After(after) {
+				next = next.AddDate(1, 0, 0)
+			}
+		}
+		return next, nil
+	}
+
+	from := after.AddDate(0, 0, 1)
+	if start.After(from) {
+		from = start
+	}
+
+	if r.Kind == KindWeekdays {
+		best := 7
+		for _, wd := range r.Weekdays {
+			best = min(best, (wd-isoWeekday(from)+7)%7)
+		}
+		return from.AddDate(0, 0, best), nil
+	}
+
+	// m и mw: перебираем месяцы, начиная с месяца from
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12*horizonYears; i++ {
+		if i%ctxCheckPeriods == 0 {
+			if err := ctx.Err(); err != nil {
+				return time.Time{}, err
+			}
+		}
+		if len(r.Months) == 0 || slices.Contains(r.Months, int(month.Month())) {
+			minDay := 1
+			if i == 0 {
+				minDay = from.Day()
+			}
+			if day := r.firstDayIn(month, minDay); day > 0 {
+				return month.AddDate(0, 0, day-1), nil
+			}
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+	return time.Time{}, fmt.Errorf("%w в ближайшие %d лет: %s", ErrNoDates, horizonYears, r)
+}
+
+// firstDayIn возвращает наименьший день месяца month не раньше minDay,
+// подходящий под правило m или mw, либо 0.
+func (r Rule) firstDayIn(month time.Time, minDay int) int {
+	last := daysIn(month.Year(), month.Month())
+	best := 0
+	try := func(day int) {
+		if day >= minDay && day >= 1 && day <= last && (best == 0 || day < best) {
+			best = day
+		}
+	}
+
+	if r.Kind == KindMonthDays {
+		for _, day := range r.Days {
+			if day < 0 {
+				day = last + day + 1
+			}
+			try(day)
+		}
+		return best
+	}
+
+	firstWd := isoWeekday(month)
+	lastWd := (firstWd+last-2)%7 + 1
+	for _, d := range r.Nth {
+		if d.N > 0 {
+			try(1 + (d.Weekday-firstWd+7)%7 + 7*(d.N-1))
+		} else {
+			try(last - (lastWd-d.Weekday+7)%7 + 7*(d.N+1))
+		}
+	}
+	return best
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSeriesEnded — следующей даты нет: серия повторений закончилась.
+var ErrSeriesEnded = errors.New("серия повторений завершена")
+
+// Limits — ограничения серии повторений.
+type Limits struct {
+	// Until — последняя допустимая дата в формате DateFormat.
+	Until string
+	// Count — общее число повторений, 0 — без ограничения.
+	Count int
+}
+
+// SplitRepeat отделяет от правила повторения модификаторы в конце строки:
+// until:<дата> (20060102 или 02.01.2006) и count:<число>, например
+// "d 7 until:20251231" или "w 1,3 count:10".
+//
+// Для правила RRULE ограничения берутся из COUNT и UNTIL, а само правило
+// возвращается как есть.
+func SplitRepeat(repeat string) (string, Limits, error) {
+	if IsRRule(repeat) {
+		r, err := ParseRRule(repeat)
+		if err != nil {
+			return "", Limits{}, err
+		}
+		return repeat, Limits{Until: r.Until, Count: r.Count}, nil
+	}
+
+	var limits Limits
+	parts := strings.Split(repeat, " ")
+	for len(parts) > 1 {
+		key, value, ok := strings.Cut(parts[len(parts)-1], ":")
+		if !ok || key != "until" && key != "count" {
+			break
+		}
+
+		switch key {
+		case "until":
+			if limits.Until != "" {
+				return "", Limits{}, fmt.Errorf("until указан дважды: %s", repeat)
+			}
+			until, err := parseUntil(value)
+			if err != nil {
+				return "", Limits{}, err
+			}
+			limits.Until = until
+		case "count":
+			if limits.Count != 0 {
+				return "", Limits{}, fmt.Errorf("count указан дважды: %s", repeat)
+			}
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 || count > 10000 {
+				return "", Limits{}, fmt.Errorf("неверное количество повторений: %s", value)
+			}
+			limits.Count = count
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	return strings.Join(parts, " "), limits, nil
+}
+
+func parseUntil(value string) (string, error) {
+	for _, layout := range []string{DateFormat, "02.01.2006"} {
+		if d, err := time.Parse(layout, value); err == nil {
+			return d.Format(DateFormat), nil
+		}
+	}
+	return "", fmt.Errorf("неверная дата until: %s", value)
+}
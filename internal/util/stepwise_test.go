@@ -0,0 +1,262 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stepwiseNextDate — прежняя реализация NextDate, перебиравшая дни по одному.
+// Оставлена для сравнения с Rule.Next в тестах и бенчмарках.
+func stepwiseNextDate(ctx context.Context, now time.Time, date string, repeat string) (string, error) {
+	Start, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат начальной даты: %v", err)
+	}
+	today, _ := time.Parse(DateFormat, now.Format(DateFormat))
+
+	horizon := today
+	if Start.After(horizon) {
+		horizon = Start
+	}
+	horizon = horizon.AddDate(horizonYears, 0, 0)
+	var steps int
+	// nextDay сдвигает Start на день вперёд, пока не достигнут горизонт
+	// и не отменён ctx.
+	nextDay := func() error {
+		Start = Start.AddDate(0, 0, 1)
+		if Start.After(horizon) {
+			return fmt.Errorf("%w в ближайшие %d лет: %s", ErrNoDates, horizonYears, repeat)
+		}
+		if steps++; steps%1024 == 0 {
+			return ctx.Err()
+		}
+		return nil
+	}
+	// правила w, m и mw не привязаны к начальной дате: дни до now
+	// можно не перебирать
+	fromToday := func() {
+		if Start.Before(today) {
+			Start = today
+		}
+	}
+
+	if IsRRule(repeat) {
+		r, err := ParseRRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		next, err := r.Next(Start, now)
+		if err != nil {
+			return "", err
+		}
+		return next.Format(DateFormat), nil
+	}
+
+	Repeat := strings.Split(repeat, " ")
+
+	if len(Repeat) != 0 {
+		switch Repeat[0] {
+		case "d":
+			if len(Repeat) < 2 {
+				return "", fmt.Errorf("неверный формат repeat для d: %s", repeat)
+			}
+			days, err := strconv.Atoi(Repeat[1])
+			if err != nil || days > 400 || days < 1 {
+				return "", fmt.Errorf("неверное количество дней: %v", err)
+			}
+			// пропускаем целые интервалы до now
+			if Start.Before(today) {
+				skip := int((today.Unix() - Start.Unix()) / 86400 / int64(days))
+				Start = Start.AddDate(0, 0, skip*days)
+			}
+			Start = Start.AddDate(0, 0, days)
+			for Start.Format(DateFormat) <= now.Format(DateFormat) {
+				Start = Start.AddDate(0, 0, days)
+			}
+			return Start.Format(DateFormat), nil
+		case "y":
+			if len(Repeat) != 1 {
+				return "", fmt.Errorf("неверный формат repeat для y: %s", repeat)
+			}
+			Start = Start.AddDate(1, 0, 0)
+			for Start.Format(DateFormat) <= now.Format(DateFormat) {
+				Start = Start.AddDate(1, 0, 0)
+			}
+			return Start.Format(DateFormat), nil
+		case "w":
+			if len(Repeat) != 2 {
+				return "", fmt.Errorf("неверный формат repeat для w: %s", repeat)
+			}
+			week := strings.Split(Repeat[1], ",")
+
+			var targetDays []int
+
+			for _, w := range week {
+				day, err := strconv.Atoi(w)
+				if err != nil || day < 1 || day > 7 {
+					return "", fmt.Errorf("неверный день недели: %v", err)
+				}
+				targetDays = append(targetDays, day)
+			}
+
+			fromToday()
+			for {
+				weekDay := int(Start.Weekday())
+				if weekDay == 0 {
+					weekDay = 7
+				}
+
+				for _, day := range targetDays {
+					if weekDay == day && Start.Format(DateFormat) > now.Format(DateFormat) {
+						return Start.Format(DateFormat), nil
+					}
+				}
+				if err := nextDay(); err != nil {
+					return "", err
+				}
+			}
+		case "m":
+			if len(Repeat) < 2 {
+				return "", fmt.Errorf("неверный формат repeat для m: %s", repeat)
+			}
+
+			var months []int
+			if len(Repeat) > 2 {
+				monthStr := strings.Split(Repeat[2], ",")
+				for _, m := range monthStr {
+					month, err := strconv.Atoi(m)
+					if err != nil || month < 1 || month > 12 {
+						return "", fmt.Errorf("неверный месяц: %v", err)
+					}
+					months = append(months, month)
+				}
+			}
+
+			var days []int
+			for _, d := range strings.Split(Repeat[1], ",") {
+				day, err := strconv.Atoi(d)
+				if err != nil || day > 31 || day < -2 || day == 0 {
+					return "", fmt.Errorf("неверный день: %s", d)
+				}
+				days = append(days, day)
+			}
+			if !monthDaysPossible(days, months) {
+				return "", fmt.Errorf("%w: в указанных месяцах нет дней %s", ErrNoDates, Repeat[1])
+			}
+
+			fromToday()
+			for {
+				dayMatch := false
+				for _, day := range days {
+					if day < 0 {
+						lastDay := time.Date(Start.Year(), Start.Month()+1, 0, 0, 0, 0, 0, Start.Location()).Day()
+						calculatedDay := lastDay + day + 1
+						if calculatedDay < 1 {
+							return "", fmt.Errorf("некорректный день месяца: %d", calculatedDay)
+						}
+						day = calculatedDay
+					}
+					if Start.Day() == day {
+						dayMatch = true
+						break
+					}
+				}
+
+				monthMatched := len(months) == 0
+				for _, m := range months {
+					if int(Start.Month()) == m {
+						monthMatched = true
+						break
+					}
+				}
+
+				if dayMatch && monthMatched && Start.Format(DateFormat) > now.Format(DateFormat) {
+					return Start.Format(DateFormat), nil
+				}
+				if err := nextDay(); err != nil {
+					return "", err
+				}
+			}
+
+		case "mw":
+			// mw <n>:<день недели>[,...] [месяцы]: n — номер дня недели в месяце
+			// (1..5) или с конца месяца (-1 — последний), день недели 1..7.
+			if len(Repeat) < 2 || len(Repeat) > 3 {
+				return "", fmt.Errorf("неверный формат repeat для mw: %s", repeat)
+			}
+
+			type nthWeekday struct {
+				n, weekday int
+			}
+			var targets []nthWeekday
+			for _, pair := range strings.Split(Repeat[1], ",") {
+				nStr, wdStr, ok := strings.Cut(pair, ":")
+				if !ok {
+					return "", fmt.Errorf("неверный формат mw, ожидается номер:день недели: %s", pair)
+				}
+				n, err := strconv.Atoi(nStr)
+				if err != nil || n == 0 || n > 5 || n < -5 {
+					return "", fmt.Errorf("неверный номер недели: %s", nStr)
+				}
+				weekday, err := strconv.Atoi(wdStr)
+				if err != nil || weekday < 1 || weekday > 7 {
+					return "", fmt.Errorf("неверный день недели: %s", wdStr)
+				}
+				targets = append(targets, nthWeekday{n, weekday})
+			}
+
+			var months []int
+			if len(Repeat) > 2 {
+				for _, m := range strings.Split(Repeat[2], ",") {
+					month, err := strconv.Atoi(m)
+					if err != nil || month < 1 || month > 12 {
+						return "", fmt.Errorf("неверный месяц: %s", m)
+					}
+					months = append(months, month)
+				}
+			}
+
+			fromToday()
+			for {
+				weekDay := int(Start.Weekday())
+				if weekDay == 0 {
+					weekDay = 7
+				}
+				lastDay := time.Date(Start.Year(), Start.Month()+1, 0, 0, 0, 0, 0, Start.Location()).Day()
+				fromStart := (Start.Day()-1)/7 + 1
+				fromEnd := -((lastDay-Start.Day())/7 + 1)
+
+				dayMatch := false
+				for _, t := range targets {
+					if t.weekday == weekDay && (t.n == fromStart || t.n == fromEnd) {
+						dayMatch = true
+						break
+					}
+				}
+
+				monthMatched := len(months) == 0
+				for _, m := range months {
+					if int(Start.Month()) == m {
+						monthMatched = true
+						break
+					}
+				}
+
+				if dayMatch && monthMatched && Start.Format(DateFormat) > now.Format(DateFormat) {
+					return Start.Format(DateFormat), nil
+				}
+				if err := nextDay(); err != nil {
+					return "", err
+				}
+			}
+
+		default:
+			return "", fmt.Errorf("правило повторения указано в неправильном формате: %v", repeat)
+		}
+	}
+
+	return now.Format(DateFormat), nil
+}
@@ -1,40 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
 
+	"github.com/Kovarniykrab/finishGolang/internal/auth"
+	"github.com/Kovarniykrab/finishGolang/internal/config"
 	"github.com/Kovarniykrab/finishGolang/internal/database"
 	"github.com/Kovarniykrab/finishGolang/internal/server"
 )
 
-const defaultPort = 7540
-
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	setupLogging(cfg.LogLevel)
+
+	if cfg.MigrateDryRun {
+		if err := database.PrintPendingMigrations(cfg.DBURL, cfg.DBFile, os.Stdout); err != nil {
+			log.Fatalf("Ошибка проверки миграций: %v", err)
+		}
+		return
+	}
+
 	// Инициализация БД
-	database, err := database.InitDB()
+	store, err := database.Open(cfg.DBURL, cfg.DBFile)
 	if err != nil {
 		log.Fatalf("Ошибка инициализации БД: %v", err)
 	}
-	defer database.Close()
 
-	// Получаем порт и запускаем сервер
-	port := getPort()
-	if err := server.Start(port, database); err != nil {
+	secret, err := store.AuthSecret()
+	if err != nil {
+		store.Close()
+		log.Fatalf("Ошибка чтения ключа подписи: %v", err)
+	}
+	auth.SetSecret(secret)
+	auth.SetPassword(cfg.Password)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Сервер сам закрывает БД после остановки
+	if err := server.Start(ctx, cfg, store); err != nil {
 		fmt.Printf("Ошибка при запуске сервера: %v\n", err)
 		os.Exit(1)
 	}
+	log.Println("Сервер остановлен")
 }
 
-func getPort() int {
-	portStr := os.Getenv("TODO_PORT")
-	if portStr != "" {
-		port, err := strconv.Atoi(portStr)
-		if err == nil && port > 0 {
-			return port
-		}
+// setupLogging направляет стандартный log через slog с уровнем из конфигурации.
+func setupLogging(level string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		l = slog.LevelInfo
 	}
-	return defaultPort
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l})))
 }
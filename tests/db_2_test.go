@@ -16,6 +16,12 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+	OwnerID int64  `db:"owner_id"`
+	Version int64  `db:"version"`
+	Created string `db:"created_at"`
+	Remain  int    `db:"remaining"`
+	Anchor  string `db:"anchor"`
+	CatchUp string `db:"catch_up"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNextDateNthWeekday проверяет правило mw — n-й день недели месяца.
+func TestNextDateNthWeekday(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "mw 2:2", "20240213"},
+		{"20240101", "mw -1:5", "20240223"},
+		{"20240301", "mw -1:5", "20240329"},
+		{"20240101", "mw 1:1", "20240205"},
+		{"20240101", "mw 5:2", "20240130"},
+		{"20240101", "mw -1:7", "20240128"},
+		{"20240101", "mw 2:2,-1:5", "20240213"},
+		{"20240101", "mw 1:1 3", "20240304"},
+		{"20231201", "mw -5:3 1,2", "20250101"},
+		{"20240126", "mw", ""},
+		{"20240126", "mw 2", ""},
+		{"20240126", "mw 2-2", ""},
+		{"20240126", "mw 0:1", ""},
+		{"20240126", "mw 6:1", ""},
+		{"20240126", "mw 2:8", ""},
+		{"20240126", "mw 2:2 13", ""},
+		{"20240126", "mw 2:2 1 2", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if len(v.want) == 0 {
+			assert.NotRegexp(t, `^\d{8}$`, next, `{%q, %q}`, v.date, v.repeat)
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`, v.date, v.repeat, v.want)
+	}
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNextDateRRule проверяет правила повторения в формате RRULE.
+func TestNextDateRRule(t *testing.T) {
+	tbl := []nextDate{
+		{"20240101", "FREQ=DAILY;INTERVAL=10", "20240131"},
+		{"20240101", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR", "20240129"},
+		{"20240101", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", "20240130"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=2TU", "20240213"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "FREQ=MONTHLY;BYMONTHDAY=15;BYMONTH=3", "20240315"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "20241128"},
+		{"20240101", "FREQ=DAILY;INTERVAL=10;UNTIL=20240201", "20240131"},
+		{"20240101", "FREQ=DAILY;INTERVAL=10;UNTIL=20240130", ""},
+		{"20240101", "FREQ=DAILY;COUNT=3;UNTIL=20240201", ""},
+		{"20240101", "FREQ=MINUTELY", ""},
+		{"20240101", "FREQ=WEEKLY;BYDAY=2TU", ""},
+		{"20240101", "BYDAY=TU", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		if len(v.want) == 0 {
+			assert.NotRegexp(t, `^\d{8}$`, next, `{%q, %q}`, v.date, v.repeat)
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`, v.date, v.repeat, v.want)
+	}
+}
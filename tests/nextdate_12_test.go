@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNextDateImpossible проверяет, что правила без единой даты сразу
+// возвращают ошибку, а не зацикливаются.
+func TestNextDateImpossible(t *testing.T) {
+	for _, repeat := range []string{
+		"m 31 2",
+		"m 30,31 2",
+		"m 31 4,6,9,11",
+		"m 0",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		"FREQ=MONTHLY;BYMONTHDAY=31;BYDAY=1MO",
+	} {
+		start := time.Now()
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=20240126&repeat=%s", url.QueryEscape(repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		assert.NotRegexp(t, `^\d{8}$`, strings.TrimSpace(string(get)), repeat)
+		assert.Less(t, time.Since(start), time.Second, repeat)
+	}
+}
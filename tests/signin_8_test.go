@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signin(t *testing.T, password string) (*http.Response, map[string]string) {
+	data, err := json.Marshal(map[string]string{"password": password})
+	assert.NoError(t, err)
+	resp, err := http.Post(getURL("api/signin"), "application/json", bytes.NewReader(data))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	var m map[string]string
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	return resp, m
+}
+
+func TestSignin(t *testing.T) {
+	password := os.Getenv("TODO_PASSWORD")
+	if len(password) == 0 {
+		t.Skip("TODO_PASSWORD не задан")
+	}
+
+	_, m := signin(t, password+"x")
+	assert.NotEmpty(t, m["error"])
+	assert.Empty(t, m["token"])
+
+	resp, err := http.Get(getURL("api/tasks"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, m = signin(t, password)
+	token := m["token"]
+	assert.NotEmpty(t, token)
+
+	for _, value := range []string{token, token + "x", "ooops"} {
+		req, err := http.NewRequest(http.MethodGet, getURL("api/tasks"), nil)
+		assert.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: "token", Value: value})
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		if value == token {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		} else {
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func userRequest(t *testing.T, token, apipath string, values map[string]any, method string) (int, map[string]any) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if len(token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return resp.StatusCode, m
+}
+
+func register(t *testing.T, login string) string {
+	_, m := userRequest(t, "", "api/register", map[string]any{
+		"login":           login,
+		"password":        "password123",
+		"shared_password": os.Getenv("TODO_PASSWORD"),
+	}, http.MethodPost)
+	token, _ := m["token"].(string)
+	assert.NotEmpty(t, token, "ошибка регистрации: %v", m)
+	return token
+}
+
+func TestUsers(t *testing.T) {
+	suffix := time.Now().UnixNano()
+	alice := register(t, fmt.Sprintf("alice%d", suffix))
+	bob := register(t, fmt.Sprintf("bob%d", suffix))
+
+	_, m := userRequest(t, "", "api/register", map[string]any{
+		"login":           fmt.Sprintf("alice%d", suffix),
+		"password":        "password123",
+		"shared_password": os.Getenv("TODO_PASSWORD"),
+	}, http.MethodPost)
+	assert.NotEmpty(t, m["error"])
+
+	_, m = userRequest(t, "", "api/login", map[string]any{
+		"login":    fmt.Sprintf("alice%d", suffix),
+		"password": "wrong password",
+	}, http.MethodPost)
+	assert.NotEmpty(t, m["error"])
+
+	_, m = userRequest(t, "", "api/login", map[string]any{
+		"login":    fmt.Sprintf("alice%d", suffix),
+		"password": "password123",
+	}, http.MethodPost)
+	assert.NotEmpty(t, m["token"])
+
+	_, m = userRequest(t, alice, "api/task", map[string]any{
+		"date":  time.Now().Format(`20060102`),
+		"title": "Задача Алисы",
+	}, http.MethodPost)
+	id := fmt.Sprint(m["id"])
+	assert.NotEmpty(t, id)
+
+	_, m = userRequest(t, alice, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, "Задача Алисы", m["title"])
+
+	_, m = userRequest(t, alice, "api/tasks", nil, http.MethodGet)
+	assert.Len(t, m["tasks"], 1)
+	_, m = userRequest(t, bob, "api/tasks", nil, http.MethodGet)
+	assert.Len(t, m["tasks"], 0)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		_, m = userRequest(t, bob, "api/task?id="+id, nil, method)
+		assert.NotEmpty(t, m["error"], method)
+	}
+	_, m = userRequest(t, bob, "api/task", map[string]any{
+		"id":    id,
+		"date":  time.Now().Format(`20060102`),
+		"title": "Чужая задача",
+	}, http.MethodPut)
+	assert.NotEmpty(t, m["error"])
+	_, m = userRequest(t, bob, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.NotEmpty(t, m["error"])
+
+	_, m = userRequest(t, alice, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, "Задача Алисы", m["title"])
+	_, m = userRequest(t, alice, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.Empty(t, m)
+}